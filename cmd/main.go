@@ -4,16 +4,29 @@ import (
 	"context"
 	"dataservice/internal/api"
 	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/apikey"
+	"dataservice/internal/api/breaker"
+	"dataservice/internal/api/cache"
+	"dataservice/internal/api/fallback"
 	"dataservice/internal/api/genderapi"
 	"dataservice/internal/api/nationalizeapi"
+	"dataservice/internal/api/quota"
+	"dataservice/internal/api/retry"
+	"dataservice/internal/api/table"
 	"dataservice/internal/manager"
+	"dataservice/internal/migrations"
 	"dataservice/internal/pgxprovider"
 	"dataservice/internal/server"
 	"dataservice/internal/userdb/db"
+	"dataservice/internal/worker"
 	"errors"
+	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -22,6 +35,9 @@ import (
 )
 
 func main() {
+	autoMigrate := flag.Bool("auto-migrate", false, "apply pending database migrations on start")
+	flag.Parse()
+
 	ctx, cancel := signal.NotifyContext(context.Background(),
 		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
@@ -35,17 +51,46 @@ func main() {
 	defer log.Sync()
 
 	pgxp, err := pgxprovider.New(pgxprovider.Config{
-		URL: os.Getenv("POSTGRES_URL"),
+		URL:               os.Getenv("POSTGRES_URL"),
+		MinConns:          2,
+		MaxConns:          16,
+		MaxConnLifetime:   time.Hour,
+		MaxConnIdleTime:   10 * time.Minute,
+		HealthCheckPeriod: time.Minute,
 	})
 	if err != nil {
 		log.Error("failed postges:", zap.Error(err))
 		return
 	}
-	defer pgxp.Close(context.Background())
+	defer pgxp.Close()
+
+	migrator, err := migrations.New(migrations.Dependencies{
+		Log: log,
+		PGX: pgxp,
+	})
+	if err != nil {
+		log.Error("failed to load migrations:", zap.Error(err))
+		return
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(ctx, migrator, flag.Args()[1:]); err != nil {
+			log.Error("failed to migrate:", zap.Error(err))
+		}
+		return
+	}
+
+	if *autoMigrate {
+		if _, err := migrator.Up(ctx); err != nil {
+			log.Error("failed to migrate:", zap.Error(err))
+			return
+		}
+	}
 
 	db := db.New(
 		db.Config{
-			QueryTimeout: 1 * time.Second,
+			QueryTimeout:       1 * time.Second,
+			SlowQueryThreshold: 200 * time.Millisecond,
 		},
 		db.Dependencies{
 			Log: log,
@@ -53,54 +98,313 @@ func main() {
 		},
 	)
 
-	client := http.Client{}
+	const batchTimeout = 10 * time.Second
 
-	ageapi := ageapi.NewAgify(
-		ageapi.Config{
-			URI: os.Getenv("AGIFY_URI"),
-		},
-		ageapi.Dependencies{
-			Client: &client,
-			Log:    log,
-		},
-	)
+	quotas := make([]*quota.Transport, 0, 3)
+	newClient := func(cfg quota.Config) *http.Client {
+		q := quota.New(cfg, quota.Dependencies{
+			Log: log,
+		})
+		quotas = append(quotas, q)
 
-	genderapi := genderapi.NewGenderize(
-		genderapi.Config{
-			URI: os.Getenv("GENDERIZE_URI"),
-		},
-		genderapi.Dependencies{
-			Client: &client,
-			Log:    log,
-		},
-	)
+		return &http.Client{
+			Transport: retry.New(
+				retry.Config{
+					Attempts:  3,
+					BaseDelay: 100 * time.Millisecond,
+					MaxDelay:  time.Second,
+					Jitter:    0.5,
+				},
+				retry.Dependencies{
+					Next: q,
+					Log:  log,
+				},
+			),
+		}
+	}
 
-	nationalizeapi := nationalizeapi.NewNationalize(
-		nationalizeapi.Config{
-			URI: os.Getenv("NATIONALIZE_URI"),
-		},
-		nationalizeapi.Dependencies{
-			Client: &client,
-			Log:    log,
-		},
-	)
+	var cacheStore cache.Store
+	if persistent, _ := strconv.ParseBool(os.Getenv("ENRICHMENT_CACHE_PERSISTENT")); persistent {
+		cacheStore = cache.NewPostgresStore(pgxp)
+	}
 
-	api := api.NewAPI(
-		api.Dependencies{
-			Age:         ageapi,
-			Gender:      genderapi,
-			Nationalize: nationalizeapi,
-		},
-	)
+	caches := make([]*cache.Cache, 0, 3)
+	newCache := func(provider string) *cache.Cache {
+		c := cache.New(
+			cache.Config{
+				Provider:    provider,
+				TTL:         24 * time.Hour,
+				NegativeTTL: time.Hour,
+				Size:        10000,
+			},
+			cache.Dependencies{
+				Store: cacheStore,
+				Log:   log,
+			},
+		)
+		caches = append(caches, c)
+		return c
+	}
+
+	breakers := make([]*breaker.Breaker, 0, 3)
+	newBreaker := func(provider string) *breaker.Breaker {
+		b := breaker.New(
+			breaker.Config{
+				Provider:         provider,
+				FailureThreshold: 5,
+				OpenTimeout:      30 * time.Second,
+				HalfOpenRequests: 1,
+			},
+			breaker.Dependencies{
+				Log: log,
+			},
+		)
+		breakers = append(breakers, b)
+		return b
+	}
+
+	// source is a provider of a fallback chain, reached through its own
+	// quota, retries, breaker and cache.
+	type source struct {
+		provider string
+		uri      string
+		key      string
+		client   *http.Client
+		table    *table.Table
+		timeout  time.Duration
+		// names is how many names the source takes within the batch
+		// timeout, -1 for any number.
+		names int
+	}
+	newSource := func(provider, prefix string) (source, error) {
+		timeout, err := timeoutConfig(prefix)
+		if err != nil {
+			return source{}, err
+		}
+
+		if path := os.Getenv(prefix + "_TABLE"); path != "" {
+			t, err := table.Load(path)
+			if err != nil {
+				return source{}, fmt.Errorf("failed to load table: %w", err)
+			}
+			return source{provider: provider, table: t, timeout: timeout, names: -1}, nil
+		}
+
+		quotaCfg, err := quotaConfig(provider, prefix)
+		if err != nil {
+			return source{}, fmt.Errorf("invalid quota: %w", err)
+		}
+
+		key, err := apikey.Load(prefix)
+		if err != nil {
+			return source{}, fmt.Errorf("failed to load API key: %w", err)
+		}
+
+		return source{
+			provider: provider,
+			uri:      os.Getenv(prefix + "_URI"),
+			key:      key,
+			client:   newClient(quotaCfg),
+			timeout:  timeout,
+			names:    quotaCfg.Names(batchTimeout),
+		}, nil
+	}
+	// sources lists the provider followed by its fallbacks <prefix>_FALLBACK_1,
+	// <prefix>_FALLBACK_2 and so on, each set with either _URI or _TABLE.
+	sources := func(provider, prefix string) ([]source, error) {
+		ret := make([]source, 0, 1)
+		for n := 0; ; n++ {
+			name, p := provider, prefix
+			if n > 0 {
+				name, p = fmt.Sprintf("%s-fallback-%d", provider, n), fmt.Sprintf("%s_FALLBACK_%d", prefix, n)
+				if os.Getenv(p+"_URI") == "" && os.Getenv(p+"_TABLE") == "" {
+					return ret, nil
+				}
+			}
+
+			s, err := newSource(name, p)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, s)
+		}
+	}
+
+	// batchNames is how many names a fallback chain takes at once. A batch
+	// is sent whole to one source, so it is the most any of them takes.
+	batchNames := func(sources []source) int {
+		ret := 0
+		for _, s := range sources {
+			if s.names < 0 {
+				return -1
+			}
+			ret = max(ret, s.names)
+		}
+		return ret
+	}
+
+	agifySources, err := sources("agify", "AGIFY")
+	if err != nil {
+		log.Error("failed to configure agify:", zap.Error(err))
+		return
+	}
+	ageChain := make([]fallback.Entry[ageapi.AgeAPI], 0, len(agifySources))
+	for _, s := range agifySources {
+		if s.table != nil {
+			ageChain = append(ageChain, fallback.Entry[ageapi.AgeAPI]{
+				Name:    s.provider,
+				API:     table.NewAgeAPI(s.provider, s.table),
+				Timeout: s.timeout,
+			})
+			continue
+		}
+
+		agify := ageapi.NewAgify(
+			ageapi.Config{
+				URI:    s.uri,
+				APIKey: s.key,
+			},
+			ageapi.Dependencies{
+				Client: s.client,
+				Log:    log,
+			},
+		)
+		ageChain = append(ageChain, fallback.Entry[ageapi.AgeAPI]{
+			Name: s.provider,
+			API: cache.NewAgeAPI(
+				breaker.NewAgeAPI(agify, newBreaker(s.provider)),
+				newCache(s.provider)),
+			Timeout: s.timeout,
+		})
+	}
+
+	genderizeSources, err := sources("genderize", "GENDERIZE")
+	if err != nil {
+		log.Error("failed to configure genderize:", zap.Error(err))
+		return
+	}
+	genderChain := make([]fallback.Entry[genderapi.GenderAPI], 0, len(genderizeSources))
+	for _, s := range genderizeSources {
+		if s.table != nil {
+			genderChain = append(genderChain, fallback.Entry[genderapi.GenderAPI]{
+				Name:    s.provider,
+				API:     table.NewGenderAPI(s.provider, s.table),
+				Timeout: s.timeout,
+			})
+			continue
+		}
+
+		genderize := genderapi.NewGenderize(
+			genderapi.Config{
+				URI:    s.uri,
+				APIKey: s.key,
+			},
+			genderapi.Dependencies{
+				Client: s.client,
+				Log:    log,
+			},
+		)
+		genderChain = append(genderChain, fallback.Entry[genderapi.GenderAPI]{
+			Name: s.provider,
+			API: cache.NewGenderAPI(
+				breaker.NewGenderAPI(genderize, newBreaker(s.provider)),
+				newCache(s.provider)),
+			Timeout: s.timeout,
+		})
+	}
+
+	nationalizeSources, err := sources("nationalize", "NATIONALIZE")
+	if err != nil {
+		log.Error("failed to configure nationalize:", zap.Error(err))
+		return
+	}
+	nationalizeChain := make([]fallback.Entry[nationalizeapi.NationalizeAPI], 0, len(nationalizeSources))
+	for _, s := range nationalizeSources {
+		if s.table != nil {
+			nationalizeChain = append(nationalizeChain, fallback.Entry[nationalizeapi.NationalizeAPI]{
+				Name:    s.provider,
+				API:     table.NewNationalizeAPI(s.provider, s.table),
+				Timeout: s.timeout,
+			})
+			continue
+		}
+
+		nationalize := nationalizeapi.NewNationalize(
+			nationalizeapi.Config{
+				URI:    s.uri,
+				APIKey: s.key,
+			},
+			nationalizeapi.Dependencies{
+				Client: s.client,
+				Log:    log,
+			},
+		)
+		nationalizeChain = append(nationalizeChain, fallback.Entry[nationalizeapi.NationalizeAPI]{
+			Name: s.provider,
+			API: cache.NewNationalizeAPI(
+				breaker.NewNationalizeAPI(nationalize, newBreaker(s.provider)),
+				newCache(s.provider)),
+			Timeout: s.timeout,
+		})
+	}
+
+	registry := api.NewRegistry()
+	for _, p := range []api.Provider{
+		api.NewAgeProvider("agify", fallback.NewAgeAPI(ageChain, log)),
+		api.NewGenderProvider("genderize", fallback.NewGenderAPI(genderChain, log)),
+		api.NewNationalizeProvider("nationalize", fallback.NewNationalizeAPI(nationalizeChain, log)),
+	} {
+		if err := registry.Register(p); err != nil {
+			log.Error("failed to register provider:", zap.Error(err))
+			return
+		}
+	}
+
+	names := registry.Names()
+	if v := os.Getenv("ENRICHMENT_PROVIDERS"); v != "" {
+		names = strings.Split(strings.ReplaceAll(v, " ", ""), ",")
+	}
+	providers, err := registry.Select(names)
+	if err != nil {
+		log.Error("invalid enrichment providers:", zap.Error(err))
+		return
+	}
+
+	// Batches are capped at what the most limited provider takes within
+	// the batch timeout.
+	chainNames := map[string]int{
+		"agify":       batchNames(agifySources),
+		"genderize":   batchNames(genderizeSources),
+		"nationalize": batchNames(nationalizeSources),
+	}
+	maxBatch := 0
+	for _, p := range providers {
+		if n := chainNames[p.Name()]; n >= 0 && (maxBatch == 0 || n < maxBatch) {
+			maxBatch = n
+		}
+	}
+
+	policy, err := manager.ParsePolicy(os.Getenv("ENRICHMENT_POLICY"))
+	if err != nil {
+		log.Error("invalid enrichment policy:", zap.Error(err))
+		return
+	}
+
+	localize, _ := strconv.ParseBool(os.Getenv("ENRICHMENT_LOCALIZE"))
 
 	manager := manager.New(
 		manager.Config{
-			Timeout: time.Second,
+			Timeout:      time.Second,
+			BatchTimeout: batchTimeout,
+			MaxBatch:     maxBatch,
+			Policy:       policy,
+			Localize:     localize,
+			MinSamples:   50,
 		},
 		manager.Dependencies{
-			API: api,
-			DB:  db,
-			Log: log,
+			Providers: providers,
+			DB:        db,
+			Log:       log,
 		},
 	)
 
@@ -109,11 +413,82 @@ func main() {
 			Address: os.Getenv("SERVER_ADDR"),
 		},
 		server.Dependencies{
-			Manager: *manager,
-			Log:     log,
+			Manager:  *manager,
+			PGX:      pgxp,
+			Caches:   caches,
+			Breakers: breakers,
+			Quotas:   quotas,
+			Log:      log,
 		},
 	)
+	worker := worker.New(
+		worker.Config{
+			Interval:   time.Minute,
+			BatchSize:  100,
+			StaleAfter: 30 * 24 * time.Hour,
+			RetryAfter: 24 * time.Hour,
+			Rate:       1,
+			Timeout:    time.Second,
+			Localize:   localize,
+			MinSamples: 50,
+		},
+		worker.Dependencies{
+			Providers: providers,
+			DB:        db,
+			Log:       log,
+		},
+	)
+
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		if err := worker.Run(ctx); err != nil {
+			log.Error("failed to run worker:", zap.Error(err))
+		}
+	}()
+
 	if err = server.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
 		log.Error("failed to run server:", zap.Error(err))
 	}
+	cancel()
+	<-workerDone
+}
+
+// quotaConfig reads the provider's budgets from <prefix>_QUOTA_PER_SECOND
+// and <prefix>_QUOTA_PER_DAY, unset meaning unlimited.
+func quotaConfig(provider, prefix string) (quota.Config, error) {
+	cfg := quota.Config{Provider: provider}
+
+	if v := os.Getenv(prefix + "_QUOTA_PER_SECOND"); v != "" {
+		perSecond, err := strconv.ParseFloat(v, 64)
+		if err != nil || perSecond < 0 {
+			return quota.Config{}, fmt.Errorf("%s_QUOTA_PER_SECOND: invalid value %q", prefix, v)
+		}
+		cfg.PerSecond = perSecond
+	}
+
+	if v := os.Getenv(prefix + "_QUOTA_PER_DAY"); v != "" {
+		perDay, err := strconv.Atoi(v)
+		if err != nil || perDay < 0 {
+			return quota.Config{}, fmt.Errorf("%s_QUOTA_PER_DAY: invalid value %q", prefix, v)
+		}
+		cfg.PerDay = perDay
+	}
+
+	return cfg, nil
+}
+
+// timeoutConfig reads how long the provider is given to answer from
+// <prefix>_TIMEOUT, unset meaning no limit of its own.
+func timeoutConfig(prefix string) (time.Duration, error) {
+	v := os.Getenv(prefix + "_TIMEOUT")
+	if v == "" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(v)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("%s_TIMEOUT: invalid value %q", prefix, v)
+	}
+	return timeout, nil
 }
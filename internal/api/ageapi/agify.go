@@ -2,13 +2,17 @@ package ageapi
 
 import (
 	"context"
-	"encoding/json"
-	"io"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/schema"
 	"net/http"
+	"net/url"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+const provider = "agify"
+
 type agifyResponse struct {
 	Count int    `json:"count"`
 	Name  string `json:"name"`
@@ -17,6 +21,8 @@ type agifyResponse struct {
 
 type Config struct {
 	URI string
+	// APIKey is sent with every request when set.
+	APIKey string
 }
 
 type Dependencies struct {
@@ -25,51 +31,61 @@ type Dependencies struct {
 }
 
 type agify struct {
-	cfg  Config
-	deps Dependencies
-
-	log *zap.Logger
+	client *upstream.Client
 }
 
 func NewAgify(cfg Config, deps Dependencies) AgeAPI {
 	return &agify{
-		cfg:  cfg,
-		deps: deps,
-		log:  deps.Log.Named("agify"),
+		client: upstream.NewClient(upstream.Config{
+			Provider: provider,
+			URI:      cfg.URI,
+			APIKey:   cfg.APIKey,
+		}, upstream.Dependencies{
+			Client: deps.Client,
+			Log:    deps.Log,
+		}),
 	}
 }
 
-func (ag *agify) Get(ctx context.Context, name string) (int, error) {
-	req, err := http.NewRequest(http.MethodGet, ag.cfg.URI, nil)
-	if err != nil {
-		ag.log.Error("failed to create http request", zap.Error(err))
-		return 0, err
+func (res agifyResponse) result() Result {
+	return Result{
+		Age: res.Age,
+		Enrichment: schema.Enrichment{
+			Provider:  provider,
+			Count:     res.Count,
+			FetchedAt: time.Now(),
+		},
 	}
+}
 
-	q := req.URL.Query()
-	q.Add("name", name)
-	req.URL.RawQuery = q.Encode()
+func (ag *agify) Get(ctx context.Context, name string) (Result, error) {
+	res := agifyResponse{}
+	if err := ag.client.Do(ctx, url.Values{"name": {name}}, &res); err != nil {
+		return Result{}, err
+	}
+	return res.result(), nil
+}
 
-	req = req.WithContext(ctx)
-	resp, err := ag.deps.Client.Do(req)
-	if err != nil {
-		ag.log.Error("failed to do http request", zap.Error(err))
-		return 0, err
+func (ag *agify) GetLocalized(ctx context.Context, name, countryID string) (Result, error) {
+	res := agifyResponse{}
+	if err := ag.client.Do(ctx, url.Values{"name": {name}, "country_id": {countryID}}, &res); err != nil {
+		return Result{}, err
 	}
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	ret := res.result()
+	ret.CountryID = countryID
+	return ret, nil
+}
+
+func (ag *agify) GetBatch(ctx context.Context, names []string) ([]Result, error) {
+	res, err := upstream.GetBatch[agifyResponse](ctx, ag.client, names)
 	if err != nil {
-		ag.log.Error("failed to read response body", zap.Error(err))
-		return 0, err
+		return nil, err
 	}
 
-	res := agifyResponse{}
-	if err := json.Unmarshal(body, &res); err != nil {
-		ag.log.Error("failed to unmarshal response", zap.Error(err))
-		return 0, err
+	ret := make([]Result, 0, len(res))
+	for _, r := range res {
+		ret = append(ret, r.result())
 	}
-
-	ag.log.Debug("success request", zap.Any("resp", res))
-	return res.Age, nil
+	return ret, nil
 }
@@ -1,8 +1,21 @@
 package ageapi
 
-import "context"
+import (
+	"context"
+	"dataservice/internal/schema"
+)
+
+type Result struct {
+	Age int
+	schema.Enrichment
+}
 
 //go:generate mockgen -package ageapi -destination api_mock.go . AgeAPI
 type AgeAPI interface {
-	Get(_ context.Context, name string) (age int, _ error)
+	Get(_ context.Context, name string) (Result, error)
+	// GetLocalized answers for the people of the country with the given
+	// ISO 3166-1 alpha-2 code.
+	GetLocalized(_ context.Context, name, countryID string) (Result, error)
+	// GetBatch returns results in the order of names.
+	GetBatch(_ context.Context, names []string) ([]Result, error)
 }
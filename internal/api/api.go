@@ -1,41 +1,35 @@
 package api
 
 import (
-	"dataservice/internal/api/ageapi"
-	"dataservice/internal/api/genderapi"
-	"dataservice/internal/api/nationalizeapi"
+	"context"
+	"dataservice/internal/schema"
 )
 
-type API interface {
-	AgeAPI() ageapi.AgeAPI
-	GenderAPI() genderapi.GenderAPI
-	NationalizeAPI() nationalizeapi.NationalizeAPI
+// Query is what a provider is asked about a person.
+type Query struct {
+	Name string
+	// CountryID localizes the answer for providers that support it, see
+	// LocalizedAge.
+	CountryID  string
+	MinSamples int
 }
 
-type Dependencies struct {
-	Age ageapi.AgeAPI
-	Gender genderapi.GenderAPI
-	Nationalize nationalizeapi.NationalizeAPI
+// Answer is a provider's value for its attribute, of the type of the
+// attribute's schema.PersonInfo field.
+type Answer struct {
+	Value any
+	schema.Enrichment
 }
 
-type api struct {
-	deps Dependencies
-}
-
-func NewAPI(deps Dependencies) API {
-	return &api{
-		deps: deps,
-	}
-}
-
-func (api *api) AgeAPI() ageapi.AgeAPI {
-	return api.deps.Age
-}
-
-func (api *api) GenderAPI() genderapi.GenderAPI {
-	return api.deps.Gender
-}
-
-func (api *api) NationalizeAPI() nationalizeapi.NationalizeAPI {
-	return api.deps.Nationalize
+// Provider fills in one attribute of a person.
+//
+//go:generate mockgen -package api -destination api_mock.go . Provider
+type Provider interface {
+	// Name identifies the provider in the configuration.
+	Name() string
+	// Attribute is the attribute the provider fills, such as schema.AttrAge.
+	Attribute() string
+	Lookup(_ context.Context, q Query) (Answer, error)
+	// LookupBatch returns the global answers in the order of names.
+	LookupBatch(_ context.Context, names []string) ([]Answer, error)
 }
@@ -0,0 +1,98 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: dataservice/internal/api (interfaces: Provider)
+//
+// Generated by this command:
+//
+//	mockgen -package api -destination api_mock.go . Provider
+//
+
+// Package api is a generated GoMock package.
+package api
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockProvider is a mock of Provider interface.
+type MockProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockProviderMockRecorder
+}
+
+// MockProviderMockRecorder is the mock recorder for MockProvider.
+type MockProviderMockRecorder struct {
+	mock *MockProvider
+}
+
+// NewMockProvider creates a new mock instance.
+func NewMockProvider(ctrl *gomock.Controller) *MockProvider {
+	mock := &MockProvider{ctrl: ctrl}
+	mock.recorder = &MockProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProvider) EXPECT() *MockProviderMockRecorder {
+	return m.recorder
+}
+
+// Attribute mocks base method.
+func (m *MockProvider) Attribute() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Attribute")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Attribute indicates an expected call of Attribute.
+func (mr *MockProviderMockRecorder) Attribute() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Attribute", reflect.TypeOf((*MockProvider)(nil).Attribute))
+}
+
+// Lookup mocks base method.
+func (m *MockProvider) Lookup(arg0 context.Context, arg1 Query) (Answer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lookup", arg0, arg1)
+	ret0, _ := ret[0].(Answer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Lookup indicates an expected call of Lookup.
+func (mr *MockProviderMockRecorder) Lookup(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lookup", reflect.TypeOf((*MockProvider)(nil).Lookup), arg0, arg1)
+}
+
+// LookupBatch mocks base method.
+func (m *MockProvider) LookupBatch(arg0 context.Context, arg1 []string) ([]Answer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LookupBatch", arg0, arg1)
+	ret0, _ := ret[0].([]Answer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LookupBatch indicates an expected call of LookupBatch.
+func (mr *MockProviderMockRecorder) LookupBatch(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupBatch", reflect.TypeOf((*MockProvider)(nil).LookupBatch), arg0, arg1)
+}
+
+// Name mocks base method.
+func (m *MockProvider) Name() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Name")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Name indicates an expected call of Name.
+func (mr *MockProviderMockRecorder) Name() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockProvider)(nil).Name))
+}
@@ -0,0 +1,59 @@
+package apikey
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Param is the query parameter the providers expect the key in.
+const Param = "apikey"
+
+const redacted = "REDACTED"
+
+// Load reads the key from <prefix>_API_KEY or from the file named by
+// <prefix>_API_KEY_FILE, returning an empty key when neither is set.
+func Load(prefix string) (string, error) {
+	key, file := os.Getenv(prefix+"_API_KEY"), os.Getenv(prefix+"_API_KEY_FILE")
+	if key != "" && file != "" {
+		return "", fmt.Errorf("both %s_API_KEY and %s_API_KEY_FILE are set", prefix, prefix)
+	}
+	if file == "" {
+		return key, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("%s_API_KEY_FILE: %w", prefix, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Redact hides key in the message of err, such as the request URL of an
+// *url.Error, leaving the error chain intact.
+func Redact(err error, key string) error {
+	if err == nil || key == "" {
+		return err
+	}
+
+	msg := err.Error()
+	ret := strings.NewReplacer(url.QueryEscape(key), redacted, key, redacted).Replace(msg)
+	if ret == msg {
+		return err
+	}
+	return &redactedError{err: err, msg: ret}
+}
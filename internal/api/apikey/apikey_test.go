@@ -0,0 +1,61 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Setenv("AGIFY_API_KEY", "")
+	t.Setenv("AGIFY_API_KEY_FILE", "")
+
+	key, err := Load("AGIFY")
+	require.NoError(t, err)
+	require.Empty(t, key)
+
+	t.Setenv("AGIFY_API_KEY", "secret")
+	key, err = Load("AGIFY")
+	require.NoError(t, err)
+	require.Equal(t, "secret", key)
+
+	file := filepath.Join(t.TempDir(), "agify")
+	require.NoError(t, os.WriteFile(file, []byte("from-file\n"), 0o600))
+	t.Setenv("AGIFY_API_KEY_FILE", file)
+	_, err = Load("AGIFY")
+	require.Error(t, err)
+
+	t.Setenv("AGIFY_API_KEY", "")
+	key, err = Load("AGIFY")
+	require.NoError(t, err)
+	require.Equal(t, "from-file", key)
+
+	t.Setenv("AGIFY_API_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+	_, err = Load("AGIFY")
+	require.Error(t, err)
+}
+
+func TestRedact(t *testing.T) {
+	err := &url.Error{
+		Op:  "Get",
+		URL: "https://api.agify.io/?apikey=s%2Bcret&name=Dmitry",
+		Err: context.DeadlineExceeded,
+	}
+
+	redacted := Redact(err, "s+cret")
+	require.Equal(t, `Get "https://api.agify.io/?apikey=REDACTED&name=Dmitry": context deadline exceeded`,
+		redacted.Error())
+	require.ErrorIs(t, redacted, context.DeadlineExceeded)
+
+	var ue *url.Error
+	require.True(t, errors.As(redacted, &ue))
+
+	require.Equal(t, "invalid key s+cret", Redact(errors.New("invalid key s+cret"), "").Error())
+	require.Equal(t, "invalid key REDACTED", Redact(errors.New("invalid key s+cret"), "s+cret").Error())
+	require.Nil(t, Redact(nil, "s+cret"))
+}
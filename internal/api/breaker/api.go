@@ -0,0 +1,125 @@
+package breaker
+
+import (
+	"context"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/genderapi"
+	"dataservice/internal/api/nationalizeapi"
+)
+
+type ageAPI struct {
+	next    ageapi.AgeAPI
+	breaker *Breaker
+}
+
+func NewAgeAPI(next ageapi.AgeAPI, breaker *Breaker) ageapi.AgeAPI {
+	return &ageAPI{
+		next:    next,
+		breaker: breaker,
+	}
+}
+
+func (a *ageAPI) Get(ctx context.Context, name string) (ageapi.Result, error) {
+	res := ageapi.Result{}
+	err := a.breaker.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = a.next.Get(ctx, name)
+		return err
+	})
+	return res, err
+}
+
+func (a *ageAPI) GetLocalized(ctx context.Context, name, countryID string) (ageapi.Result, error) {
+	res := ageapi.Result{}
+	err := a.breaker.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = a.next.GetLocalized(ctx, name, countryID)
+		return err
+	})
+	return res, err
+}
+
+func (a *ageAPI) GetBatch(ctx context.Context, names []string) ([]ageapi.Result, error) {
+	var ret []ageapi.Result
+	err := a.breaker.Do(ctx, func(ctx context.Context) (err error) {
+		ret, err = a.next.GetBatch(ctx, names)
+		return err
+	})
+	return ret, err
+}
+
+type genderAPI struct {
+	next    genderapi.GenderAPI
+	breaker *Breaker
+}
+
+func NewGenderAPI(next genderapi.GenderAPI, breaker *Breaker) genderapi.GenderAPI {
+	return &genderAPI{
+		next:    next,
+		breaker: breaker,
+	}
+}
+
+func (g *genderAPI) Get(ctx context.Context, name string) (genderapi.Result, error) {
+	res := genderapi.Result{}
+	err := g.breaker.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = g.next.Get(ctx, name)
+		return err
+	})
+	return res, err
+}
+
+func (g *genderAPI) GetLocalized(ctx context.Context, name, countryID string) (genderapi.Result, error) {
+	res := genderapi.Result{}
+	err := g.breaker.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = g.next.GetLocalized(ctx, name, countryID)
+		return err
+	})
+	return res, err
+}
+
+func (g *genderAPI) GetBatch(ctx context.Context, names []string) ([]genderapi.Result, error) {
+	var ret []genderapi.Result
+	err := g.breaker.Do(ctx, func(ctx context.Context) (err error) {
+		ret, err = g.next.GetBatch(ctx, names)
+		return err
+	})
+	return ret, err
+}
+
+type nationalizeAPI struct {
+	next    nationalizeapi.NationalizeAPI
+	breaker *Breaker
+}
+
+func NewNationalizeAPI(next nationalizeapi.NationalizeAPI, breaker *Breaker) nationalizeapi.NationalizeAPI {
+	return &nationalizeAPI{
+		next:    next,
+		breaker: breaker,
+	}
+}
+
+func (n *nationalizeAPI) Get(ctx context.Context, name string) (nationalizeapi.Result, error) {
+	res := nationalizeapi.Result{}
+	err := n.breaker.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = n.next.Get(ctx, name)
+		return err
+	})
+	return res, err
+}
+
+func (n *nationalizeAPI) GetBatch(ctx context.Context, names []string) ([]nationalizeapi.Result, error) {
+	var ret []nationalizeapi.Result
+	err := n.breaker.Do(ctx, func(ctx context.Context) (err error) {
+		ret, err = n.next.GetBatch(ctx, names)
+		return err
+	})
+	return ret, err
+}
+
+func (n *nationalizeAPI) Rank(ctx context.Context, name string) (nationalizeapi.Result, error) {
+	res := nationalizeapi.Result{}
+	err := n.breaker.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = n.next.Rank(ctx, name)
+		return err
+	})
+	return res, err
+}
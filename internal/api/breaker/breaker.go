@@ -0,0 +1,223 @@
+package breaker
+
+import (
+	"context"
+	"dataservice/internal/api/quota"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/errs"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+	defaultHalfOpenRequests = 1
+)
+
+var ErrOpen = errs.New(errs.KindUnavailable, "circuit breaker is open")
+
+type State uint8
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Config sets when the breaker trips: after FailureThreshold consecutive
+// failures it rejects calls for OpenTimeout, then lets HalfOpenRequests
+// trial calls through and closes once all of them succeed.
+type Config struct {
+	Provider         string
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	HalfOpenRequests int
+}
+
+type Dependencies struct {
+	Log *zap.Logger
+}
+
+type Stats struct {
+	Provider  string    `json:"provider"`
+	State     string    `json:"state"`
+	Failures  int       `json:"failures"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type Breaker struct {
+	cfg  Config
+	deps Dependencies
+	log  *zap.Logger
+
+	mu        sync.Mutex
+	state     State
+	changedAt time.Time
+	failures  int
+	// generation changes with every state transition so results of calls
+	// started in a previous state are ignored.
+	generation uint64
+	inFlight   int
+	successes  int
+
+	now func() time.Time
+}
+
+func New(cfg Config, deps Dependencies) *Breaker {
+	if cfg.FailureThreshold == 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	if cfg.OpenTimeout == 0 {
+		cfg.OpenTimeout = defaultOpenTimeout
+	}
+	if cfg.HalfOpenRequests == 0 {
+		cfg.HalfOpenRequests = defaultHalfOpenRequests
+	}
+
+	return &Breaker{
+		cfg:       cfg,
+		deps:      deps,
+		log:       deps.Log.Named("breaker").With(zap.String("provider", cfg.Provider)),
+		changedAt: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (b *Breaker) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return Stats{
+		Provider:  b.cfg.Provider,
+		State:     b.state.String(),
+		Failures:  b.failures,
+		ChangedAt: b.changedAt,
+	}
+}
+
+// Do calls fn unless the breaker is open, in which case it fails fast with
+// ErrOpen.
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	generation, err := b.allow()
+	if err != nil {
+		return err
+	}
+
+	err = fn(ctx)
+	b.record(generation, err)
+	return err
+}
+
+func (b *Breaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.changedAt) < b.cfg.OpenTimeout {
+			return 0, fmt.Errorf("%s: %w", b.cfg.Provider, ErrOpen)
+		}
+		b.setState(StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.inFlight+b.successes >= b.cfg.HalfOpenRequests {
+			return 0, fmt.Errorf("%s: %w", b.cfg.Provider, ErrOpen)
+		}
+		b.inFlight++
+	}
+	return b.generation, nil
+}
+
+func (b *Breaker) record(generation uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	// A trial call that tells nothing about the provider only frees its
+	// slot.
+	if ignored(err) {
+		if b.state == StateHalfOpen {
+			b.inFlight--
+		}
+		return
+	}
+
+	failed := failure(err)
+
+	switch b.state {
+	case StateClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		b.inFlight--
+		if failed {
+			b.failures++
+			b.setState(StateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenRequests {
+			b.failures = 0
+			b.setState(StateClosed)
+		}
+	}
+}
+
+func (b *Breaker) setState(state State) {
+	b.log.Info("circuit breaker state changed",
+		zap.Stringer("from", b.state),
+		zap.Stringer("to", state),
+		zap.Int("failures", b.failures))
+
+	b.state = state
+	b.changedAt = b.now()
+	b.generation++
+	b.inFlight = 0
+	b.successes = 0
+}
+
+// ignored reports whether err tells nothing about the provider's health:
+// the call was canceled by its caller or held back by our own quota.
+func ignored(err error) bool {
+	var qe *quota.Error
+	return errors.Is(err, context.Canceled) || errors.As(err, &qe)
+}
+
+// failure reports whether err says the provider is unhealthy. Requests the
+// provider rejected as invalid do not.
+func failure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var ue *upstream.Error
+	if errors.As(err, &ue) {
+		return ue.Temporary()
+	}
+	return true
+}
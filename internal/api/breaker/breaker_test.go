@@ -0,0 +1,148 @@
+package breaker
+
+import (
+	"context"
+	"dataservice/internal/api/genderapi"
+	"dataservice/internal/api/quota"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/errs"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestBreaker(cfg Config) (*Breaker, *clock) {
+	clk := &clock{now: time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)}
+	b := New(cfg, Dependencies{Log: zap.NewNop()})
+	b.now = clk.Now
+	return b, clk
+}
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	next := genderapi.NewMockGenderAPI(ctrl)
+
+	b, clk := newTestBreaker(Config{Provider: "genderize", FailureThreshold: 2, OpenTimeout: time.Minute})
+	api := NewGenderAPI(next, b)
+
+	failure := errors.New("connection refused")
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(genderapi.Result{}, failure).Times(2)
+	for i := 0; i < 2; i++ {
+		_, err := api.Get(ctx, "Dmitry")
+		require.ErrorIs(t, err, failure)
+	}
+	require.Equal(t, StateOpen.String(), b.Stats().State)
+
+	_, err := api.Get(ctx, "Dmitry")
+	require.ErrorIs(t, err, ErrOpen)
+	require.Equal(t, errs.KindUnavailable, errs.KindOf(err))
+
+	clk.now = clk.now.Add(time.Minute)
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(genderapi.Result{}, failure)
+	_, err = api.Get(ctx, "Dmitry")
+	require.ErrorIs(t, err, failure)
+	require.Equal(t, StateOpen.String(), b.Stats().State)
+
+	clk.now = clk.now.Add(time.Minute)
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(genderapi.Result{Gender: "male"}, nil)
+	gender, err := api.Get(ctx, "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, "male", gender.Gender)
+	require.Equal(t, Stats{
+		Provider:  "genderize",
+		State:     StateClosed.String(),
+		ChangedAt: clk.now,
+	}, b.Stats())
+}
+
+func TestBreakerResetsOnSuccess(t *testing.T) {
+	b, _ := newTestBreaker(Config{FailureThreshold: 2})
+
+	failure := errors.New("failure")
+	fail := func(context.Context) error { return failure }
+	succeed := func(context.Context) error { return nil }
+
+	for i := 0; i < 3; i++ {
+		require.Error(t, b.Do(context.Background(), fail))
+		require.NoError(t, b.Do(context.Background(), succeed))
+	}
+	require.Equal(t, StateClosed.String(), b.Stats().State)
+
+	require.ErrorIs(t, b.Do(context.Background(), func(context.Context) error {
+		return context.Canceled
+	}), context.Canceled)
+	require.Error(t, b.Do(context.Background(), fail))
+	require.Equal(t, StateClosed.String(), b.Stats().State)
+
+	for i := 0; i < 3; i++ {
+		require.Error(t, b.Do(context.Background(), func(context.Context) error {
+			return &upstream.Error{Provider: "agify", Status: 422}
+		}))
+		require.Error(t, b.Do(context.Background(), func(context.Context) error {
+			return &quota.Error{Provider: "agify", Reset: time.Hour}
+		}))
+	}
+	require.Equal(t, StateClosed.String(), b.Stats().State)
+
+	for i := 0; i < 2; i++ {
+		require.Error(t, b.Do(context.Background(), func(context.Context) error {
+			return &upstream.Error{Provider: "agify", Status: 429}
+		}))
+	}
+	require.Equal(t, StateOpen.String(), b.Stats().State)
+}
+
+func TestBreakerHalfOpenLimit(t *testing.T) {
+	b, clk := newTestBreaker(Config{FailureThreshold: 1, OpenTimeout: time.Second, HalfOpenRequests: 1})
+
+	require.Error(t, b.Do(context.Background(), func(context.Context) error {
+		return errors.New("failure")
+	}))
+
+	clk.now = clk.now.Add(time.Second)
+	err := b.Do(context.Background(), func(context.Context) error {
+		// Only one trial call is let through while half-open.
+		require.ErrorIs(t, b.Do(context.Background(), func(context.Context) error {
+			return nil
+		}), ErrOpen)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, StateClosed.String(), b.Stats().State)
+}
+
+func TestBreakerHalfOpenCanceled(t *testing.T) {
+	b, clk := newTestBreaker(Config{FailureThreshold: 1, OpenTimeout: time.Second, HalfOpenRequests: 2})
+
+	require.Error(t, b.Do(context.Background(), func(context.Context) error {
+		return errors.New("failure")
+	}))
+
+	// Trial calls abandoned by their callers neither close the breaker nor
+	// keep their slots.
+	clk.now = clk.now.Add(time.Second)
+	for i := 0; i < 3; i++ {
+		require.ErrorIs(t, b.Do(context.Background(), func(context.Context) error {
+			return context.Canceled
+		}), context.Canceled)
+	}
+	require.Equal(t, StateHalfOpen.String(), b.Stats().State)
+
+	require.NoError(t, b.Do(context.Background(), func(context.Context) error { return nil }))
+	require.Equal(t, StateHalfOpen.String(), b.Stats().State)
+	require.NoError(t, b.Do(context.Background(), func(context.Context) error { return nil }))
+	require.Equal(t, StateClosed.String(), b.Stats().State)
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/genderapi"
+	"dataservice/internal/api/nationalizeapi"
+	"dataservice/internal/schema"
+)
+
+// localeKey keeps localized results apart from global ones in the same
+// cache.
+func localeKey(name, countryID string) string {
+	return "locale/" + countryID + "/" + name
+}
+
+type ageAPI struct {
+	next  ageapi.AgeAPI
+	cache *Cache
+}
+
+func NewAgeAPI(next ageapi.AgeAPI, cache *Cache) ageapi.AgeAPI {
+	return &ageAPI{
+		next:  next,
+		cache: cache,
+	}
+}
+
+func (a *ageAPI) Get(ctx context.Context, name string) (ageapi.Result, error) {
+	res := ageapi.Result{}
+	err := a.cache.Get(ctx, name, &res, func(ctx context.Context) (any, bool, error) {
+		res, err := a.next.Get(ctx, name)
+		return res, res.Age == 0, err
+	})
+	return res, err
+}
+
+func (a *ageAPI) GetLocalized(ctx context.Context, name, countryID string) (ageapi.Result, error) {
+	res := ageapi.Result{}
+	err := a.cache.Get(ctx, localeKey(name, countryID), &res, func(ctx context.Context) (any, bool, error) {
+		res, err := a.next.GetLocalized(ctx, name, countryID)
+		return res, res.Age == 0, err
+	})
+	return res, err
+}
+
+func (a *ageAPI) GetBatch(ctx context.Context, names []string) ([]ageapi.Result, error) {
+	return getBatch(ctx, a.cache, names, a.next.GetBatch, func(res ageapi.Result) bool {
+		return res.Age == 0
+	})
+}
+
+type genderAPI struct {
+	next  genderapi.GenderAPI
+	cache *Cache
+}
+
+func NewGenderAPI(next genderapi.GenderAPI, cache *Cache) genderapi.GenderAPI {
+	return &genderAPI{
+		next:  next,
+		cache: cache,
+	}
+}
+
+func (g *genderAPI) Get(ctx context.Context, name string) (genderapi.Result, error) {
+	res := genderapi.Result{}
+	err := g.cache.Get(ctx, name, &res, func(ctx context.Context) (any, bool, error) {
+		res, err := g.next.Get(ctx, name)
+		return res, res.Gender == "", err
+	})
+	return res, err
+}
+
+func (g *genderAPI) GetLocalized(ctx context.Context, name, countryID string) (genderapi.Result, error) {
+	res := genderapi.Result{}
+	err := g.cache.Get(ctx, localeKey(name, countryID), &res, func(ctx context.Context) (any, bool, error) {
+		res, err := g.next.GetLocalized(ctx, name, countryID)
+		return res, res.Gender == "", err
+	})
+	return res, err
+}
+
+func (g *genderAPI) GetBatch(ctx context.Context, names []string) ([]genderapi.Result, error) {
+	return getBatch(ctx, g.cache, names, g.next.GetBatch, func(res genderapi.Result) bool {
+		return res.Gender == ""
+	})
+}
+
+// rankPrefix keeps ranked lists apart from Get results in the same cache.
+const rankPrefix = "rank/"
+
+type nationalizeAPI struct {
+	next  nationalizeapi.NationalizeAPI
+	cache *Cache
+}
+
+func NewNationalizeAPI(next nationalizeapi.NationalizeAPI, cache *Cache) nationalizeapi.NationalizeAPI {
+	return &nationalizeAPI{
+		next:  next,
+		cache: cache,
+	}
+}
+
+func (n *nationalizeAPI) Get(ctx context.Context, name string) (nationalizeapi.Result, error) {
+	res := nationalizeapi.Result{}
+	err := n.cache.Get(ctx, name, &res, func(ctx context.Context) (any, bool, error) {
+		res, err := n.next.Get(ctx, name)
+		return res, res.Country == schema.UnknownCountry, err
+	})
+	return res, err
+}
+
+func (n *nationalizeAPI) GetBatch(ctx context.Context, names []string) ([]nationalizeapi.Result, error) {
+	return getBatch(ctx, n.cache, names, n.next.GetBatch, func(res nationalizeapi.Result) bool {
+		return res.Country == schema.UnknownCountry
+	})
+}
+
+func (n *nationalizeAPI) Rank(ctx context.Context, name string) (nationalizeapi.Result, error) {
+	res := nationalizeapi.Result{}
+	err := n.cache.Get(ctx, rankPrefix+name, &res, func(ctx context.Context) (any, bool, error) {
+		res, err := n.next.Rank(ctx, name)
+		return res, res.Country == schema.UnknownCountry, err
+	})
+	return res, err
+}
@@ -0,0 +1,269 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTTL         = 24 * time.Hour
+	defaultNegativeTTL = time.Hour
+	defaultSize        = 10000
+)
+
+type Entry struct {
+	Value     []byte
+	ExpiresAt time.Time
+}
+
+// Store is an optional persistent tier consulted on in-memory misses.
+type Store interface {
+	Get(ctx context.Context, provider, name string) (_ Entry, ok bool, _ error)
+	Set(ctx context.Context, provider, name string, entry Entry) error
+}
+
+type Config struct {
+	Provider    string
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	Size        int
+}
+
+type Dependencies struct {
+	Store Store
+	Log   *zap.Logger
+}
+
+type Stats struct {
+	Provider string `json:"provider"`
+	Hits     int64  `json:"hits"`
+	Misses   int64  `json:"misses"`
+	Size     int    `json:"size"`
+}
+
+// LoadFunc fetches the value on a cache miss. Negative results, such as
+// a name the provider knows nothing about, are kept for NegativeTTL.
+type LoadFunc func(ctx context.Context) (value any, negative bool, _ error)
+
+type element struct {
+	key string
+	Entry
+}
+
+// Cache is a size bounded LRU cache of provider results keyed by name.
+type Cache struct {
+	cfg  Config
+	deps Dependencies
+	log  *zap.Logger
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+
+	hits   atomic.Int64
+	misses atomic.Int64
+
+	now func() time.Time
+}
+
+func New(cfg Config, deps Dependencies) *Cache {
+	if cfg.TTL == 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.NegativeTTL == 0 {
+		cfg.NegativeTTL = defaultNegativeTTL
+	}
+	if cfg.Size == 0 {
+		cfg.Size = defaultSize
+	}
+
+	return &Cache{
+		cfg:     cfg,
+		deps:    deps,
+		log:     deps.Log.Named("cache").With(zap.String("provider", cfg.Provider)),
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+		now:     time.Now,
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Provider: c.cfg.Provider,
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		Size:     size,
+	}
+}
+
+// Get decodes the cached result for key into dst, calling load on a miss.
+func (c *Cache) Get(ctx context.Context, key string, dst any, load LoadFunc) error {
+	if c.cached(ctx, key, dst) {
+		return nil
+	}
+
+	value, negative, err := load(ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := c.store(ctx, key, value, negative)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// cached decodes the cached result for key into dst and reports whether
+// there was one, counting the hit or miss.
+func (c *Cache) cached(ctx context.Context, key string, dst any) bool {
+	key = strings.ToLower(key)
+
+	if data, ok := c.lookup(ctx, key); ok {
+		// Entries written in an older format are refetched.
+		err := json.Unmarshal(data, dst)
+		if err == nil {
+			c.hits.Add(1)
+			return true
+		}
+		c.log.Warn("failed to decode cache entry", zap.String("name", key), zap.Error(err))
+	}
+	c.misses.Add(1)
+	return false
+}
+
+// store caches value for key and returns its encoding.
+func (c *Cache) store(ctx context.Context, key string, value any, negative bool) ([]byte, error) {
+	key = strings.ToLower(key)
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := c.cfg.TTL
+	if negative {
+		ttl = c.cfg.NegativeTTL
+	}
+	e := Entry{Value: data, ExpiresAt: c.now().Add(ttl)}
+
+	c.put(key, e)
+	if c.deps.Store != nil {
+		if err := c.deps.Store.Set(ctx, c.cfg.Provider, key, e); err != nil {
+			c.log.Warn("failed to persist cache entry", zap.String("name", key), zap.Error(err))
+		}
+	}
+	return data, nil
+}
+
+// getBatch serves the cached names and loads the rest with a single call,
+// keeping the order of names.
+func getBatch[T any](ctx context.Context, c *Cache, names []string,
+	load func(context.Context, []string) ([]T, error), negative func(T) bool,
+) ([]T, error) {
+	ret := make([]T, len(names))
+	missing := make([]int, 0, len(names))
+	for i, name := range names {
+		if !c.cached(ctx, name, &ret[i]) {
+			missing = append(missing, i)
+		}
+	}
+
+	if len(missing) == 0 {
+		return ret, nil
+	}
+
+	loadNames := make([]string, 0, len(missing))
+	for _, i := range missing {
+		loadNames = append(loadNames, names[i])
+	}
+
+	res, err := load(ctx, loadNames)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != len(missing) {
+		return nil, fmt.Errorf("got %d results for %d names", len(res), len(missing))
+	}
+
+	for j, i := range missing {
+		if _, err := c.store(ctx, names[i], res[j], negative(res[j])); err != nil {
+			return nil, err
+		}
+		ret[i] = res[j]
+	}
+	return ret, nil
+}
+
+func (c *Cache) lookup(ctx context.Context, key string) ([]byte, bool) {
+	if data, ok := c.get(key); ok {
+		return data, true
+	}
+
+	if c.deps.Store == nil {
+		return nil, false
+	}
+
+	e, ok, err := c.deps.Store.Get(ctx, c.cfg.Provider, key)
+	if err != nil {
+		c.log.Warn("failed to read persistent cache", zap.String("name", key), zap.Error(err))
+		return nil, false
+	}
+	if !ok || !c.now().Before(e.ExpiresAt) {
+		return nil, false
+	}
+
+	c.put(key, e)
+	return e.Value, true
+}
+
+func (c *Cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*element)
+	if !c.now().Before(e.ExpiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.Value, true
+}
+
+func (c *Cache) put(key string, e Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*element).Entry = e
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&element{key: key, Entry: e})
+
+	for c.order.Len() > c.cfg.Size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*element).key)
+	}
+}
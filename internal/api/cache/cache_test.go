@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"context"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/nationalizeapi"
+	"dataservice/internal/schema"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+type memStore map[string]Entry
+
+func (s memStore) Get(_ context.Context, provider, name string) (Entry, bool, error) {
+	e, ok := s[provider+"/"+name]
+	return e, ok, nil
+}
+
+func (s memStore) Set(_ context.Context, provider, name string, e Entry) error {
+	s[provider+"/"+name] = e
+	return nil
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestCache(cfg Config, store Store) (*Cache, *clock) {
+	clk := &clock{now: time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)}
+	c := New(cfg, Dependencies{Store: store, Log: zap.NewNop()})
+	c.now = clk.Now
+	return c, clk
+}
+
+func TestCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	next := ageapi.NewMockAgeAPI(ctrl)
+
+	c, clk := newTestCache(Config{Provider: "agify", TTL: time.Hour, NegativeTTL: time.Minute}, nil)
+	api := NewAgeAPI(next, c)
+
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(ageapi.Result{Age: 22}, nil).Times(1)
+	for i := 0; i < 3; i++ {
+		age, err := api.Get(ctx, "Dmitry")
+		require.NoError(t, err)
+		require.Equal(t, 22, age.Age)
+	}
+
+	age, err := api.Get(ctx, "dmitry")
+	require.NoError(t, err)
+	require.Equal(t, 22, age.Age)
+	require.Equal(t, Stats{Provider: "agify", Hits: 3, Misses: 1, Size: 1}, c.Stats())
+
+	clk.now = clk.now.Add(time.Hour)
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(ageapi.Result{Age: 23}, nil)
+	age, err = api.Get(ctx, "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, 23, age.Age)
+}
+
+func TestCacheNegative(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	next := nationalizeapi.NewMockNationalizeAPI(ctrl)
+
+	c, clk := newTestCache(Config{Provider: "nationalize", TTL: time.Hour, NegativeTTL: time.Minute}, nil)
+	api := NewNationalizeAPI(next, c)
+
+	next.EXPECT().Get(gomock.Any(), "Xyz").Return(nationalizeapi.Result{Country: "unknown"}, nil).Times(1)
+	for i := 0; i < 2; i++ {
+		country, err := api.Get(ctx, "Xyz")
+		require.NoError(t, err)
+		require.Equal(t, "unknown", country.Country)
+	}
+
+	clk.now = clk.now.Add(time.Minute)
+	next.EXPECT().Get(gomock.Any(), "Xyz").Return(nationalizeapi.Result{Country: "RU"}, nil)
+	country, err := api.Get(ctx, "Xyz")
+	require.NoError(t, err)
+	require.Equal(t, "RU", country.Country)
+}
+
+func TestCacheErrorsAreNotCached(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	next := ageapi.NewMockAgeAPI(ctrl)
+
+	c, _ := newTestCache(Config{Provider: "agify"}, nil)
+	api := NewAgeAPI(next, c)
+
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(ageapi.Result{Age: 0}, errors.New("timeout"))
+	_, err := api.Get(ctx, "Dmitry")
+	require.Error(t, err)
+
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(ageapi.Result{Age: 22}, nil)
+	age, err := api.Get(ctx, "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, 22, age.Age)
+}
+
+func TestCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	next := ageapi.NewMockAgeAPI(ctrl)
+
+	c, _ := newTestCache(Config{Provider: "agify", Size: 2}, nil)
+	api := NewAgeAPI(next, c)
+
+	next.EXPECT().Get(gomock.Any(), gomock.Any()).Return(ageapi.Result{Age: 30}, nil).Times(5)
+	for _, name := range []string{"Anna", "Boris", "Anna", "Dmitry", "Boris", "Anna"} {
+		_, err := api.Get(ctx, name)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, c.Stats().Size)
+}
+
+func TestCacheStore(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	next := ageapi.NewMockAgeAPI(ctrl)
+	store := memStore{}
+
+	exp := ageapi.Result{Age: 22, Enrichment: schema.Enrichment{
+		Provider:  "agify",
+		Count:     120,
+		FetchedAt: time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC),
+	}}
+
+	c, _ := newTestCache(Config{Provider: "agify"}, store)
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(exp, nil).Times(1)
+	_, err := NewAgeAPI(next, c).Get(ctx, "Dmitry")
+	require.NoError(t, err)
+	require.Contains(t, store, "agify/dmitry")
+
+	restarted, _ := newTestCache(Config{Provider: "agify"}, store)
+	age, err := NewAgeAPI(next, restarted).Get(ctx, "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, exp, age)
+	require.Equal(t, int64(1), restarted.Stats().Hits)
+}
+
+func TestCacheOldFormat(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	next := ageapi.NewMockAgeAPI(ctrl)
+
+	c, clk := newTestCache(Config{Provider: "agify"}, memStore{})
+	c.put("dmitry", Entry{Value: []byte("22"), ExpiresAt: clk.now.Add(time.Hour)})
+
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(ageapi.Result{Age: 23}, nil)
+	age, err := NewAgeAPI(next, c).Get(ctx, "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, 23, age.Age)
+}
+
+func TestCacheRank(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	next := nationalizeapi.NewMockNationalizeAPI(ctrl)
+
+	c, _ := newTestCache(Config{Provider: "nationalize"}, nil)
+	api := NewNationalizeAPI(next, c)
+
+	exp := nationalizeapi.Result{Country: "RU", Enrichment: schema.Enrichment{
+		Provider:   "nationalize",
+		Candidates: []schema.Candidate{{Value: "RU", Probability: 0.6}, {Value: "UA", Probability: 0.2}},
+	}}
+	next.EXPECT().Rank(gomock.Any(), "Dmitry").Return(exp, nil).Times(1)
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(nationalizeapi.Result{Country: "RU"}, nil).Times(1)
+
+	for i := 0; i < 2; i++ {
+		ranked, err := api.Rank(ctx, "Dmitry")
+		require.NoError(t, err)
+		require.Equal(t, exp, ranked)
+
+		country, err := api.Get(ctx, "Dmitry")
+		require.NoError(t, err)
+		require.Equal(t, "RU", country.Country)
+	}
+}
+
+func TestCacheBatch(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	next := ageapi.NewMockAgeAPI(ctrl)
+
+	c, _ := newTestCache(Config{Provider: "agify"}, nil)
+	api := NewAgeAPI(next, c)
+
+	next.EXPECT().Get(gomock.Any(), "Anna").Return(ageapi.Result{Age: 30}, nil)
+	_, err := api.Get(ctx, "Anna")
+	require.NoError(t, err)
+
+	next.EXPECT().GetBatch(gomock.Any(), []string{"Dmitry", "Boris"}).
+		Return([]ageapi.Result{{Age: 22}, {Age: 40}}, nil)
+	res, err := api.GetBatch(ctx, []string{"Dmitry", "anna", "Boris"})
+	require.NoError(t, err)
+	require.Equal(t, []ageapi.Result{{Age: 22}, {Age: 30}, {Age: 40}}, res)
+
+	res, err = api.GetBatch(ctx, []string{"boris", "dmitry"})
+	require.NoError(t, err)
+	require.Equal(t, []ageapi.Result{{Age: 40}, {Age: 22}}, res)
+	require.Equal(t, Stats{Provider: "agify", Hits: 3, Misses: 3, Size: 3}, c.Stats())
+}
+
+func TestCacheLocalized(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	next := ageapi.NewMockAgeAPI(ctrl)
+
+	c, _ := newTestCache(Config{Provider: "agify"}, nil)
+	api := NewAgeAPI(next, c)
+
+	next.EXPECT().Get(gomock.Any(), "Dmitry").Return(ageapi.Result{Age: 40}, nil).Times(1)
+	next.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "RU").Return(ageapi.Result{Age: 35}, nil).Times(1)
+	next.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "UA").Return(ageapi.Result{Age: 33}, nil).Times(1)
+
+	for i := 0; i < 2; i++ {
+		res, err := api.Get(ctx, "Dmitry")
+		require.NoError(t, err)
+		require.Equal(t, 40, res.Age)
+
+		res, err = api.GetLocalized(ctx, "Dmitry", "RU")
+		require.NoError(t, err)
+		require.Equal(t, 35, res.Age)
+
+		res, err = api.GetLocalized(ctx, "Dmitry", "UA")
+		require.NoError(t, err)
+		require.Equal(t, 33, res.Age)
+	}
+}
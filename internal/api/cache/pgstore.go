@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"dataservice/internal/pgxprovider"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresStore struct {
+	pgx *pgxprovider.PGXProvider
+}
+
+// NewPostgresStore keeps cache entries in the enrichment_cache table so that
+// they survive restarts.
+func NewPostgresStore(pgx *pgxprovider.PGXProvider) Store {
+	return &postgresStore{
+		pgx: pgx,
+	}
+}
+
+func (s *postgresStore) Get(ctx context.Context, provider, name string) (Entry, bool, error) {
+	e := Entry{}
+	err := s.pgx.QueryRow(ctx, `SELECT value, expires_at FROM enrichment_cache
+								WHERE provider = $1 AND name = $2 AND expires_at > now()`,
+		provider, name).Scan(&e.Value, &e.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Entry{}, false, nil
+	} else if err != nil {
+		return Entry{}, false, err
+	}
+	return e, true, nil
+}
+
+func (s *postgresStore) Set(ctx context.Context, provider, name string, e Entry) error {
+	_, err := s.pgx.Exec(ctx, `INSERT INTO enrichment_cache (provider, name, value, expires_at)
+							   VALUES ($1, $2, $3, $4)
+							   ON CONFLICT (provider, name)
+							   DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at`,
+		provider, name, e.Value, e.ExpiresAt)
+	return err
+}
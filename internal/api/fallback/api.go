@@ -0,0 +1,136 @@
+package fallback
+
+import (
+	"context"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/genderapi"
+	"dataservice/internal/api/nationalizeapi"
+
+	"go.uber.org/zap"
+)
+
+type ageAPI struct {
+	chain []Entry[ageapi.AgeAPI]
+	log   *zap.Logger
+}
+
+// NewAgeAPI asks the providers of chain in order until one answers.
+func NewAgeAPI(chain []Entry[ageapi.AgeAPI], log *zap.Logger) ageapi.AgeAPI {
+	return &ageAPI{
+		chain: chain,
+		log:   log.Named("fallback"),
+	}
+}
+
+func (a *ageAPI) Get(ctx context.Context, name string) (ageapi.Result, error) {
+	res, provider, err := try(ctx, a.log, a.chain,
+		func(ctx context.Context, api ageapi.AgeAPI) (ageapi.Result, error) {
+			return api.Get(ctx, name)
+		})
+	res.Provider = provider
+	return res, err
+}
+
+func (a *ageAPI) GetLocalized(ctx context.Context, name, countryID string) (ageapi.Result, error) {
+	res, provider, err := try(ctx, a.log, a.chain,
+		func(ctx context.Context, api ageapi.AgeAPI) (ageapi.Result, error) {
+			return api.GetLocalized(ctx, name, countryID)
+		})
+	res.Provider = provider
+	return res, err
+}
+
+func (a *ageAPI) GetBatch(ctx context.Context, names []string) ([]ageapi.Result, error) {
+	ret, provider, err := try(ctx, a.log, a.chain,
+		func(ctx context.Context, api ageapi.AgeAPI) ([]ageapi.Result, error) {
+			return api.GetBatch(ctx, names)
+		})
+	for i := range ret {
+		ret[i].Provider = provider
+	}
+	return ret, err
+}
+
+type genderAPI struct {
+	chain []Entry[genderapi.GenderAPI]
+	log   *zap.Logger
+}
+
+// NewGenderAPI asks the providers of chain in order until one answers.
+func NewGenderAPI(chain []Entry[genderapi.GenderAPI], log *zap.Logger) genderapi.GenderAPI {
+	return &genderAPI{
+		chain: chain,
+		log:   log.Named("fallback"),
+	}
+}
+
+func (g *genderAPI) Get(ctx context.Context, name string) (genderapi.Result, error) {
+	res, provider, err := try(ctx, g.log, g.chain,
+		func(ctx context.Context, api genderapi.GenderAPI) (genderapi.Result, error) {
+			return api.Get(ctx, name)
+		})
+	res.Provider = provider
+	return res, err
+}
+
+func (g *genderAPI) GetLocalized(ctx context.Context, name, countryID string) (genderapi.Result, error) {
+	res, provider, err := try(ctx, g.log, g.chain,
+		func(ctx context.Context, api genderapi.GenderAPI) (genderapi.Result, error) {
+			return api.GetLocalized(ctx, name, countryID)
+		})
+	res.Provider = provider
+	return res, err
+}
+
+func (g *genderAPI) GetBatch(ctx context.Context, names []string) ([]genderapi.Result, error) {
+	ret, provider, err := try(ctx, g.log, g.chain,
+		func(ctx context.Context, api genderapi.GenderAPI) ([]genderapi.Result, error) {
+			return api.GetBatch(ctx, names)
+		})
+	for i := range ret {
+		ret[i].Provider = provider
+	}
+	return ret, err
+}
+
+type nationalizeAPI struct {
+	chain []Entry[nationalizeapi.NationalizeAPI]
+	log   *zap.Logger
+}
+
+// NewNationalizeAPI asks the providers of chain in order until one answers.
+func NewNationalizeAPI(chain []Entry[nationalizeapi.NationalizeAPI], log *zap.Logger) nationalizeapi.NationalizeAPI {
+	return &nationalizeAPI{
+		chain: chain,
+		log:   log.Named("fallback"),
+	}
+}
+
+func (n *nationalizeAPI) Get(ctx context.Context, name string) (nationalizeapi.Result, error) {
+	res, provider, err := try(ctx, n.log, n.chain,
+		func(ctx context.Context, api nationalizeapi.NationalizeAPI) (nationalizeapi.Result, error) {
+			return api.Get(ctx, name)
+		})
+	res.Provider = provider
+	return res, err
+}
+
+func (n *nationalizeAPI) GetBatch(ctx context.Context, names []string) ([]nationalizeapi.Result, error) {
+	ret, provider, err := try(ctx, n.log, n.chain,
+		func(ctx context.Context, api nationalizeapi.NationalizeAPI) ([]nationalizeapi.Result, error) {
+			return api.GetBatch(ctx, names)
+		})
+	for i := range ret {
+		ret[i].Provider = provider
+	}
+	return ret, err
+}
+
+func (n *nationalizeAPI) Rank(ctx context.Context, name string) (nationalizeapi.Result, error) {
+	res, provider, err := try(ctx, n.log, n.chain,
+		func(ctx context.Context, api nationalizeapi.NationalizeAPI) (nationalizeapi.Result, error) {
+			return api.Rank(ctx, name)
+		})
+	res.Provider = provider
+	return res, err
+}
@@ -0,0 +1,62 @@
+package fallback
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+)
+
+// Entry is one provider of a chain.
+type Entry[T any] struct {
+	// Name is recorded as the provider of the answers it gives.
+	Name string
+	API  T
+	// Timeout bounds each call to the provider, after which the next one is
+	// tried. Zero leaves only the caller's deadline.
+	Timeout time.Duration
+}
+
+// try calls the entries in order until one succeeds, returning its answer
+// and name. It gives up early once ctx is done.
+func try[T, R any](ctx context.Context, log *zap.Logger, chain []Entry[T],
+	call func(context.Context, T) (R, error),
+) (R, string, error) {
+	var (
+		ret  R
+		rerr error
+	)
+	for i, e := range chain {
+		res, err := callWithTimeout(ctx, e, call)
+		if err == nil {
+			if i > 0 {
+				log.Info("answered by fallback provider", zap.String("provider", e.Name))
+			}
+			return res, e.Name, nil
+		}
+
+		rerr = multierror.Append(rerr, err)
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(chain)-1 {
+			log.Warn("provider failed, trying the next one",
+				zap.String("provider", e.Name),
+				zap.String("next", chain[i+1].Name),
+				zap.Error(err))
+		}
+	}
+	return ret, "", rerr
+}
+
+func callWithTimeout[T, R any](ctx context.Context, e Entry[T],
+	call func(context.Context, T) (R, error),
+) (R, error) {
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+	return call(ctx, e.API)
+}
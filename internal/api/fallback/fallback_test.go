@@ -0,0 +1,111 @@
+package fallback
+
+import (
+	"context"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/nationalizeapi"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/errs"
+	"dataservice/internal/schema"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestFallback(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	agify := ageapi.NewMockAgeAPI(ctrl)
+	table := ageapi.NewMockAgeAPI(ctrl)
+
+	api := NewAgeAPI([]Entry[ageapi.AgeAPI]{
+		{Name: "agify", API: agify, Timeout: 10 * time.Millisecond},
+		{Name: "table", API: table},
+	}, zap.NewNop())
+
+	agify.EXPECT().Get(gomock.Any(), "Dmitry").
+		Return(ageapi.Result{Age: 42, Enrichment: schema.Enrichment{Provider: "agify", Count: 10}}, nil)
+	res, err := api.Get(ctx, "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, ageapi.Result{Age: 42, Enrichment: schema.Enrichment{Provider: "agify", Count: 10}}, res)
+
+	failure := errs.E(errs.KindUpstream, &upstream.Error{Provider: "agify", Status: 503})
+	agify.EXPECT().Get(gomock.Any(), "Dmitry").Return(ageapi.Result{}, failure)
+	table.EXPECT().Get(gomock.Any(), "Dmitry").Return(ageapi.Result{Age: 40}, nil)
+	res, err = api.Get(ctx, "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, ageapi.Result{Age: 40, Enrichment: schema.Enrichment{Provider: "table"}}, res)
+
+	// A provider that does not answer in time is skipped.
+	agify.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "RU").
+		DoAndReturn(func(ctx context.Context, _, _ string) (ageapi.Result, error) {
+			<-ctx.Done()
+			return ageapi.Result{}, errs.E(errs.KindTimeout, ctx.Err())
+		})
+	table.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "RU").Return(ageapi.Result{Age: 38}, nil)
+	res, err = api.GetLocalized(ctx, "Dmitry", "RU")
+	require.NoError(t, err)
+	require.Equal(t, 38, res.Age)
+	require.Equal(t, "table", res.Provider)
+
+	agify.EXPECT().GetBatch(gomock.Any(), []string{"Dmitry", "Anna"}).Return(nil, failure)
+	table.EXPECT().GetBatch(gomock.Any(), []string{"Dmitry", "Anna"}).
+		Return([]ageapi.Result{{Age: 40}, {Age: 30}}, nil)
+	batch, err := api.GetBatch(ctx, []string{"Dmitry", "Anna"})
+	require.NoError(t, err)
+	require.Equal(t, []ageapi.Result{
+		{Age: 40, Enrichment: schema.Enrichment{Provider: "table"}},
+		{Age: 30, Enrichment: schema.Enrichment{Provider: "table"}},
+	}, batch)
+
+	last := errors.New("table is empty")
+	agify.EXPECT().Get(gomock.Any(), "Anna").Return(ageapi.Result{}, failure)
+	table.EXPECT().Get(gomock.Any(), "Anna").Return(ageapi.Result{}, last)
+	_, err = api.Get(ctx, "Anna")
+	require.ErrorIs(t, err, failure)
+	require.ErrorIs(t, err, last)
+	require.Equal(t, errs.KindUpstream, errs.KindOf(err))
+}
+
+func TestFallbackRank(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	nationalize := nationalizeapi.NewMockNationalizeAPI(ctrl)
+	table := nationalizeapi.NewMockNationalizeAPI(ctrl)
+
+	api := NewNationalizeAPI([]Entry[nationalizeapi.NationalizeAPI]{
+		{Name: "nationalize", API: nationalize},
+		{Name: "table", API: table},
+	}, zap.NewNop())
+
+	nationalize.EXPECT().Rank(gomock.Any(), "Dmitry").Return(nationalizeapi.Result{}, errors.New("connection refused"))
+	table.EXPECT().Rank(gomock.Any(), "Dmitry").Return(nationalizeapi.Result{Country: "RU"}, nil)
+
+	res, err := api.Rank(context.Background(), "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, "RU", res.Country)
+	require.Equal(t, "table", res.Provider)
+}
+
+func TestFallbackCanceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	nationalize := nationalizeapi.NewMockNationalizeAPI(ctrl)
+	table := nationalizeapi.NewMockNationalizeAPI(ctrl)
+
+	api := NewNationalizeAPI([]Entry[nationalizeapi.NationalizeAPI]{
+		{Name: "nationalize", API: nationalize},
+		{Name: "table", API: table},
+	}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	nationalize.EXPECT().Get(gomock.Any(), "Dmitry").
+		DoAndReturn(func(ctx context.Context, _ string) (nationalizeapi.Result, error) {
+			cancel()
+			return nationalizeapi.Result{}, ctx.Err()
+		})
+	_, err := api.Get(ctx, "Dmitry")
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -1,8 +1,21 @@
 package genderapi
 
-import "context"
+import (
+	"context"
+	"dataservice/internal/schema"
+)
+
+type Result struct {
+	Gender string
+	schema.Enrichment
+}
 
 //go:generate mockgen -package genderapi -destination api_mock.go . GenderAPI
 type GenderAPI interface {
-	Get(_ context.Context, name string) (gender string, _ error)
+	Get(_ context.Context, name string) (Result, error)
+	// GetLocalized answers for the people of the country with the given
+	// ISO 3166-1 alpha-2 code.
+	GetLocalized(_ context.Context, name, countryID string) (Result, error)
+	// GetBatch returns results in the order of names.
+	GetBatch(_ context.Context, names []string) ([]Result, error)
 }
@@ -40,10 +40,10 @@ func (m *MockGenderAPI) EXPECT() *MockGenderAPIMockRecorder {
 }
 
 // Get mocks base method.
-func (m *MockGenderAPI) Get(arg0 context.Context, arg1 string) (string, error) {
+func (m *MockGenderAPI) Get(arg0 context.Context, arg1 string) (Result, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Get", arg0, arg1)
-	ret0, _ := ret[0].(string)
+	ret0, _ := ret[0].(Result)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -53,3 +53,33 @@ func (mr *MockGenderAPIMockRecorder) Get(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockGenderAPI)(nil).Get), arg0, arg1)
 }
+
+// GetBatch mocks base method.
+func (m *MockGenderAPI) GetBatch(arg0 context.Context, arg1 []string) ([]Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBatch", arg0, arg1)
+	ret0, _ := ret[0].([]Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBatch indicates an expected call of GetBatch.
+func (mr *MockGenderAPIMockRecorder) GetBatch(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBatch", reflect.TypeOf((*MockGenderAPI)(nil).GetBatch), arg0, arg1)
+}
+
+// GetLocalized mocks base method.
+func (m *MockGenderAPI) GetLocalized(arg0 context.Context, arg1, arg2 string) (Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLocalized", arg0, arg1, arg2)
+	ret0, _ := ret[0].(Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLocalized indicates an expected call of GetLocalized.
+func (mr *MockGenderAPIMockRecorder) GetLocalized(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLocalized", reflect.TypeOf((*MockGenderAPI)(nil).GetLocalized), arg0, arg1, arg2)
+}
@@ -2,22 +2,28 @@ package genderapi
 
 import (
 	"context"
-	"encoding/json"
-	"io"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/schema"
 	"net/http"
+	"net/url"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+const provider = "genderize"
+
 type genderizeResponse struct {
 	Count       int     `json:"count"`
 	Name        string  `json:"name"`
 	Gender      string  `json:"gender"`
-	Probability float32 `json:"probability"`
+	Probability float64 `json:"probability"`
 }
 
 type Config struct {
 	URI string
+	// APIKey is sent with every request when set.
+	APIKey string
 }
 
 type Dependencies struct {
@@ -26,51 +32,62 @@ type Dependencies struct {
 }
 
 type genderize struct {
-	cfg  Config
-	deps Dependencies
-
-	log *zap.Logger
+	client *upstream.Client
 }
 
 func NewGenderize(cfg Config, deps Dependencies) GenderAPI {
 	return &genderize{
-		cfg:  cfg,
-		deps: deps,
-		log:  deps.Log.Named("genderize"),
+		client: upstream.NewClient(upstream.Config{
+			Provider: provider,
+			URI:      cfg.URI,
+			APIKey:   cfg.APIKey,
+		}, upstream.Dependencies{
+			Client: deps.Client,
+			Log:    deps.Log,
+		}),
 	}
 }
 
-func (g *genderize) Get(ctx context.Context, name string) (string, error) {
-	req, err := http.NewRequest(http.MethodGet, g.cfg.URI, nil)
-	if err != nil {
-		g.log.Error("failed to create http request", zap.Error(err))
-		return "", err
+func (res genderizeResponse) result() Result {
+	return Result{
+		Gender: res.Gender,
+		Enrichment: schema.Enrichment{
+			Provider:   provider,
+			Confidence: &res.Probability,
+			Count:      res.Count,
+			FetchedAt:  time.Now(),
+		},
 	}
+}
 
-	q := req.URL.Query()
-	q.Add("name", name)
-	req.URL.RawQuery = q.Encode()
+func (g *genderize) Get(ctx context.Context, name string) (Result, error) {
+	res := genderizeResponse{}
+	if err := g.client.Do(ctx, url.Values{"name": {name}}, &res); err != nil {
+		return Result{}, err
+	}
+	return res.result(), nil
+}
 
-	req = req.WithContext(ctx)
-	resp, err := g.deps.Client.Do(req)
-	if err != nil {
-		g.log.Error("failed to do http request", zap.Error(err))
-		return "", err
+func (g *genderize) GetLocalized(ctx context.Context, name, countryID string) (Result, error) {
+	res := genderizeResponse{}
+	if err := g.client.Do(ctx, url.Values{"name": {name}, "country_id": {countryID}}, &res); err != nil {
+		return Result{}, err
 	}
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	ret := res.result()
+	ret.CountryID = countryID
+	return ret, nil
+}
+
+func (g *genderize) GetBatch(ctx context.Context, names []string) ([]Result, error) {
+	res, err := upstream.GetBatch[genderizeResponse](ctx, g.client, names)
 	if err != nil {
-		g.log.Error("failed to read response body", zap.Error(err))
-		return "", err
+		return nil, err
 	}
 
-	res := genderizeResponse{}
-	if err := json.Unmarshal(body, &res); err != nil {
-		g.log.Error("failed to unmarshal response", zap.Error(err))
-		return "", err
+	ret := make([]Result, 0, len(res))
+	for _, r := range res {
+		ret = append(ret, r.result())
 	}
-
-	g.log.Debug("success request", zap.Any("resp", res))
-	return res.Gender, nil
+	return ret, nil
 }
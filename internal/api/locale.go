@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/genderapi"
+	"dataservice/internal/schema"
+)
+
+// DefaultMinSamples is the sample count below which a localized answer is
+// considered too weak and the global one is used instead.
+const DefaultMinSamples = 50
+
+func localized(countryID string) bool {
+	return countryID != "" && countryID != schema.UnknownCountry
+}
+
+// LocalizedAge looks up the age of name in countryID, falling back to the
+// global answer when the localized one rests on fewer than minSamples
+// people or no country is known.
+func LocalizedAge(ctx context.Context, api ageapi.AgeAPI, name, countryID string, minSamples int) (ageapi.Result, error) {
+	if !localized(countryID) {
+		return api.Get(ctx, name)
+	}
+
+	res, err := api.GetLocalized(ctx, name, countryID)
+	if err != nil || res.Count >= minSamples {
+		return res, err
+	}
+
+	if global, err := api.Get(ctx, name); err == nil {
+		return global, nil
+	}
+	return res, nil
+}
+
+// LocalizedGender is LocalizedAge for the gender.
+func LocalizedGender(ctx context.Context, api genderapi.GenderAPI, name, countryID string, minSamples int) (genderapi.Result, error) {
+	if !localized(countryID) {
+		return api.Get(ctx, name)
+	}
+
+	res, err := api.GetLocalized(ctx, name, countryID)
+	if err != nil || res.Count >= minSamples {
+		return res, err
+	}
+
+	if global, err := api.Get(ctx, name); err == nil {
+		return global, nil
+	}
+	return res, nil
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/schema"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLocalizedAge(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	age := ageapi.NewMockAgeAPI(ctrl)
+
+	local := ageapi.Result{Age: 35, Enrichment: schema.Enrichment{Count: 120, CountryID: "RU"}}
+	sparse := ageapi.Result{Age: 60, Enrichment: schema.Enrichment{Count: 3, CountryID: "IS"}}
+	global := ageapi.Result{Age: 40, Enrichment: schema.Enrichment{Count: 5000}}
+
+	age.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "RU").Return(local, nil)
+	res, err := LocalizedAge(ctx, age, "Dmitry", "RU", 50)
+	require.NoError(t, err)
+	require.Equal(t, local, res)
+
+	age.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "IS").Return(sparse, nil)
+	age.EXPECT().Get(gomock.Any(), "Dmitry").Return(global, nil)
+	res, err = LocalizedAge(ctx, age, "Dmitry", "IS", 50)
+	require.NoError(t, err)
+	require.Equal(t, global, res)
+
+	age.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "IS").Return(sparse, nil)
+	age.EXPECT().Get(gomock.Any(), "Dmitry").Return(ageapi.Result{}, errors.New("agify is down"))
+	res, err = LocalizedAge(ctx, age, "Dmitry", "IS", 50)
+	require.NoError(t, err)
+	require.Equal(t, sparse, res)
+
+	age.EXPECT().Get(gomock.Any(), "Dmitry").Return(global, nil).Times(2)
+	for _, countryID := range []string{"", schema.UnknownCountry} {
+		res, err = LocalizedAge(ctx, age, "Dmitry", countryID, 50)
+		require.NoError(t, err)
+		require.Equal(t, global, res)
+	}
+}
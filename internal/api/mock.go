@@ -8,6 +8,7 @@ import (
 	"go.uber.org/mock/gomock"
 )
 
+// APIMock backs the built-in providers with mocked clients.
 type APIMock struct {
 	Age         *ageapi.MockAgeAPI
 	Gender      *genderapi.MockGenderAPI
@@ -22,14 +23,11 @@ func NewAPIMock(ctrl *gomock.Controller) *APIMock {
 	}
 }
 
-func (m *APIMock) AgeAPI() ageapi.AgeAPI {
-	return m.Age
-}
-
-func (m *APIMock) GenderAPI() genderapi.GenderAPI {
-	return m.Gender
-}
-
-func (m *APIMock) NationalizeAPI() nationalizeapi.NationalizeAPI {
-	return m.Nationalize
+// Providers returns agify, genderize and nationalize over the mocks.
+func (m *APIMock) Providers() []Provider {
+	return []Provider{
+		NewAgeProvider("agify", m.Age),
+		NewGenderProvider("genderize", m.Gender),
+		NewNationalizeProvider("nationalize", m.Nationalize),
+	}
 }
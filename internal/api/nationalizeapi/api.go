@@ -1,8 +1,23 @@
 package nationalizeapi
 
-import "context"
+import (
+	"context"
+	"dataservice/internal/schema"
+)
+
+type Result struct {
+	Country string
+	schema.Enrichment
+}
 
 //go:generate mockgen -package nationalizeapi -destination api_mock.go . NationalizeAPI
 type NationalizeAPI interface {
-	Get(_ context.Context, name string) (nationalize string, _ error)
+	// Get returns the most likely country, keeping the next likely ones as
+	// candidates.
+	Get(_ context.Context, name string) (Result, error)
+	// GetBatch returns results in the order of names.
+	GetBatch(_ context.Context, names []string) ([]Result, error)
+	// Rank is Get keeping every candidate country rather than the most
+	// likely ones.
+	Rank(_ context.Context, name string) (Result, error)
 }
@@ -40,10 +40,10 @@ func (m *MockNationalizeAPI) EXPECT() *MockNationalizeAPIMockRecorder {
 }
 
 // Get mocks base method.
-func (m *MockNationalizeAPI) Get(arg0 context.Context, arg1 string) (string, error) {
+func (m *MockNationalizeAPI) Get(arg0 context.Context, arg1 string) (Result, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Get", arg0, arg1)
-	ret0, _ := ret[0].(string)
+	ret0, _ := ret[0].(Result)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -53,3 +53,33 @@ func (mr *MockNationalizeAPIMockRecorder) Get(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockNationalizeAPI)(nil).Get), arg0, arg1)
 }
+
+// GetBatch mocks base method.
+func (m *MockNationalizeAPI) GetBatch(arg0 context.Context, arg1 []string) ([]Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBatch", arg0, arg1)
+	ret0, _ := ret[0].([]Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBatch indicates an expected call of GetBatch.
+func (mr *MockNationalizeAPIMockRecorder) GetBatch(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBatch", reflect.TypeOf((*MockNationalizeAPI)(nil).GetBatch), arg0, arg1)
+}
+
+// Rank mocks base method.
+func (m *MockNationalizeAPI) Rank(arg0 context.Context, arg1 string) (Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rank", arg0, arg1)
+	ret0, _ := ret[0].(Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rank indicates an expected call of Rank.
+func (mr *MockNationalizeAPIMockRecorder) Rank(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rank", reflect.TypeOf((*MockNationalizeAPI)(nil).Rank), arg0, arg1)
+}
@@ -2,15 +2,23 @@ package nationalizeapi
 
 import (
 	"context"
-	"encoding/json"
-	"io"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/schema"
 	"net/http"
 	"net/url"
 	"sort"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+const (
+	provider = "nationalize"
+
+	// topCandidates is the number of countries kept as alternatives.
+	topCandidates = 3
+)
+
 type nationalizeResponse struct {
 	Count   int    `json:"count"`
 	Name    string `json:"name"`
@@ -19,11 +27,13 @@ type nationalizeResponse struct {
 
 type country struct {
 	CountryID   string  `json:"country_id"`
-	Probability float32 `json:"probability"`
+	Probability float64 `json:"probability"`
 }
 
 type Config struct {
 	URI string
+	// APIKey is sent with every request when set.
+	APIKey string
 }
 
 type Dependencies struct {
@@ -32,59 +42,94 @@ type Dependencies struct {
 }
 
 type nationalize struct {
-	cfg  Config
-	deps Dependencies
-
-	log *zap.Logger
+	client *upstream.Client
 }
 
 func NewNationalize(cfg Config, deps Dependencies) NationalizeAPI {
 	return &nationalize{
-		cfg:  cfg,
-		deps: deps,
-		log:  deps.Log.Named("nationalize"),
+		client: upstream.NewClient(upstream.Config{
+			Provider: provider,
+			URI:      cfg.URI,
+			APIKey:   cfg.APIKey,
+		}, upstream.Dependencies{
+			Client: deps.Client,
+			Log:    deps.Log,
+		}),
 	}
 }
 
-func (n *nationalize) Get(ctx context.Context, name string) (string, error) {
-	req, err := http.NewRequest(http.MethodGet, n.cfg.URI, nil)
-	if err != nil {
-		n.log.Error("failed to create http request", zap.Error(err))
-		return "", err
+func (n *nationalize) fetch(ctx context.Context, name string) (nationalizeResponse, error) {
+	res := nationalizeResponse{}
+	if err := n.client.Do(ctx, url.Values{"name": {name}}, &res); err != nil {
+		return nationalizeResponse{}, err
 	}
+	return res, nil
+}
 
-	q := url.Values{}
-	q.Add("name", name)
-	req.URL.RawQuery = q.Encode()
+// ranked returns the countries most likely first.
+func (res nationalizeResponse) ranked() []schema.Candidate {
+	ret := make([]schema.Candidate, 0, len(res.Country))
+	for _, c := range res.Country {
+		ret = append(ret, schema.Candidate{Value: c.CountryID, Probability: c.Probability})
+	}
 
-	req = req.WithContext(ctx)
-	resp, err := n.deps.Client.Do(req)
-	if err != nil {
-		n.log.Error("failed to do http request", zap.Error(err))
-		return "", err
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Probability > ret[j].Probability
+	})
+	return ret
+}
+
+func (res nationalizeResponse) result() Result {
+	ret := Result{
+		Country: schema.UnknownCountry,
+		Enrichment: schema.Enrichment{
+			Provider:  provider,
+			Count:     res.Count,
+			FetchedAt: time.Now(),
+		},
 	}
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	ranked := res.ranked()
+	if len(ranked) == 0 {
+		return ret
+	}
+
+	ret.Country = ranked[0].Value
+	ret.Confidence = &ranked[0].Probability
+	ret.Candidates = ranked[:min(len(ranked), topCandidates)]
+	return ret
+}
+
+func (n *nationalize) Get(ctx context.Context, name string) (Result, error) {
+	res, err := n.fetch(ctx, name)
 	if err != nil {
-		n.log.Error("failed to read response body", zap.Error(err))
-		return "", err
+		return Result{}, err
 	}
+	return res.result(), nil
+}
 
-	res := nationalizeResponse{}
-	if err := json.Unmarshal(body, &res); err != nil {
-		n.log.Error("failed to unmarshal response", zap.Error(err))
-		return "", err
+func (n *nationalize) GetBatch(ctx context.Context, names []string) ([]Result, error) {
+	res, err := upstream.GetBatch[nationalizeResponse](ctx, n.client, names)
+	if err != nil {
+		return nil, err
 	}
 
-	n.log.Debug("success request", zap.Any("resp", res))
-	if len(res.Country) == 0 {
-		return "unknown", nil
+	ret := make([]Result, 0, len(res))
+	for _, r := range res {
+		ret = append(ret, r.result())
 	}
+	return ret, nil
+}
 
-	sort.Slice(res.Country, func(i, j int) bool {
-		return res.Country[i].Probability < res.Country[j].Probability
-	})
+func (n *nationalize) Rank(ctx context.Context, name string) (Result, error) {
+	res, err := n.fetch(ctx, name)
+	if err != nil {
+		return Result{}, err
+	}
 
-	return res.Country[0].CountryID, nil
+	ret := res.result()
+	if len(ret.Candidates) != 0 {
+		ret.Candidates = res.ranked()
+	}
+	return ret, nil
 }
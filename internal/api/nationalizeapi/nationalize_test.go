@@ -0,0 +1,146 @@
+package nationalizeapi
+
+import (
+	"context"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/schema"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestNationalize(t *testing.T, body string) NationalizeAPI {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Dmitry", r.URL.Query().Get("name"))
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return NewNationalize(Config{URI: srv.URL}, Dependencies{
+		Client: srv.Client(),
+		Log:    zap.NewNop(),
+	})
+}
+
+func TestGet(t *testing.T) {
+	api := newTestNationalize(t, `{"count":100,"name":"Dmitry","country":[
+		{"country_id":"BY","probability":0.1},
+		{"country_id":"RU","probability":0.6},
+		{"country_id":"KZ","probability":0.05},
+		{"country_id":"UA","probability":0.2}
+	]}`)
+
+	res, err := api.Get(context.Background(), "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, "RU", res.Country)
+	require.Equal(t, 0.6, *res.Confidence)
+	require.Equal(t, 100, res.Count)
+	require.Equal(t, []schema.Candidate{
+		{Value: "RU", Probability: 0.6},
+		{Value: "UA", Probability: 0.2},
+		{Value: "BY", Probability: 0.1},
+	}, res.Candidates)
+
+	ranked, err := api.Rank(context.Background(), "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, "RU", ranked.Country)
+	require.Equal(t, "nationalize", ranked.Provider)
+	require.Equal(t, []schema.Candidate{
+		{Value: "RU", Probability: 0.6},
+		{Value: "UA", Probability: 0.2},
+		{Value: "BY", Probability: 0.1},
+		{Value: "KZ", Probability: 0.05},
+	}, ranked.Candidates)
+}
+
+func TestGetUnknown(t *testing.T) {
+	api := newTestNationalize(t, `{"count":0,"name":"Dmitry","country":[]}`)
+
+	res, err := api.Get(context.Background(), "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, schema.UnknownCountry, res.Country)
+	require.Nil(t, res.Confidence)
+	require.Empty(t, res.Candidates)
+
+	ranked, err := api.Rank(context.Background(), "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, schema.UnknownCountry, ranked.Country)
+	require.Empty(t, ranked.Candidates)
+}
+
+func TestGetBatch(t *testing.T) {
+	names := make([]string, 0, 12)
+	for i := 0; i < 12; i++ {
+		names = append(names, fmt.Sprintf("Name%d", i))
+	}
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		res := make([]nationalizeResponse, 0)
+		for _, name := range r.URL.Query()["name[]"] {
+			res = append(res, nationalizeResponse{Count: 10, Name: name, Country: []country{
+				{CountryID: "UA", Probability: 0.2},
+				{CountryID: "RU", Probability: 0.6},
+			}})
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+
+	api := NewNationalize(Config{URI: srv.URL}, Dependencies{
+		Client: srv.Client(),
+		Log:    zap.NewNop(),
+	})
+
+	res, err := api.GetBatch(context.Background(), names)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+	require.Len(t, res, len(names))
+	for _, r := range res {
+		require.Equal(t, "RU", r.Country)
+		require.Equal(t, 0.6, *r.Confidence)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	const key = "s3cr3t+key"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, key, r.URL.Query().Get("apikey"))
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"Invalid API key s3cr3t+key"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	core, logs := observer.New(zap.DebugLevel)
+	api := NewNationalize(Config{URI: srv.URL, APIKey: key}, Dependencies{
+		Client: srv.Client(),
+		Log:    zap.New(core),
+	})
+
+	_, err := api.Get(context.Background(), "Dmitry")
+	var ue *upstream.Error
+	require.True(t, errors.As(err, &ue))
+	require.Equal(t, http.StatusUnauthorized, ue.Status)
+	require.NotContains(t, err.Error(), key)
+
+	srv.Close()
+	_, err = api.Get(context.Background(), "Dmitry")
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), url.QueryEscape(key))
+
+	for _, entry := range logs.All() {
+		for _, value := range entry.ContextMap() {
+			require.NotContains(t, fmt.Sprint(value), "s3cr3t")
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/genderapi"
+	"dataservice/internal/api/nationalizeapi"
+	"dataservice/internal/schema"
+)
+
+type ageProvider struct {
+	name string
+	api  ageapi.AgeAPI
+}
+
+// NewAgeProvider fills the age from api, localized when asked to.
+func NewAgeProvider(name string, api ageapi.AgeAPI) Provider {
+	return &ageProvider{
+		name: name,
+		api:  api,
+	}
+}
+
+func (p *ageProvider) Name() string {
+	return p.name
+}
+
+func (p *ageProvider) Attribute() string {
+	return schema.AttrAge
+}
+
+func (p *ageProvider) Lookup(ctx context.Context, q Query) (Answer, error) {
+	res, err := LocalizedAge(ctx, p.api, q.Name, q.CountryID, q.MinSamples)
+	if err != nil {
+		return Answer{}, err
+	}
+	return Answer{Value: res.Age, Enrichment: res.Enrichment}, nil
+}
+
+func (p *ageProvider) LookupBatch(ctx context.Context, names []string) ([]Answer, error) {
+	res, err := p.api.GetBatch(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Answer, 0, len(res))
+	for _, r := range res {
+		ret = append(ret, Answer{Value: r.Age, Enrichment: r.Enrichment})
+	}
+	return ret, nil
+}
+
+type genderProvider struct {
+	name string
+	api  genderapi.GenderAPI
+}
+
+// NewGenderProvider fills the gender from api, localized when asked to.
+func NewGenderProvider(name string, api genderapi.GenderAPI) Provider {
+	return &genderProvider{
+		name: name,
+		api:  api,
+	}
+}
+
+func (p *genderProvider) Name() string {
+	return p.name
+}
+
+func (p *genderProvider) Attribute() string {
+	return schema.AttrGender
+}
+
+func (p *genderProvider) Lookup(ctx context.Context, q Query) (Answer, error) {
+	res, err := LocalizedGender(ctx, p.api, q.Name, q.CountryID, q.MinSamples)
+	if err != nil {
+		return Answer{}, err
+	}
+	return Answer{Value: res.Gender, Enrichment: res.Enrichment}, nil
+}
+
+func (p *genderProvider) LookupBatch(ctx context.Context, names []string) ([]Answer, error) {
+	res, err := p.api.GetBatch(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Answer, 0, len(res))
+	for _, r := range res {
+		ret = append(ret, Answer{Value: r.Gender, Enrichment: r.Enrichment})
+	}
+	return ret, nil
+}
+
+type nationalizeProvider struct {
+	name string
+	api  nationalizeapi.NationalizeAPI
+}
+
+// NewNationalizeProvider fills the country from api.
+func NewNationalizeProvider(name string, api nationalizeapi.NationalizeAPI) Provider {
+	return &nationalizeProvider{
+		name: name,
+		api:  api,
+	}
+}
+
+func (p *nationalizeProvider) Name() string {
+	return p.name
+}
+
+func (p *nationalizeProvider) Attribute() string {
+	return schema.AttrCountry
+}
+
+func (p *nationalizeProvider) Lookup(ctx context.Context, q Query) (Answer, error) {
+	res, err := p.api.Get(ctx, q.Name)
+	if err != nil {
+		return Answer{}, err
+	}
+	return Answer{Value: res.Country, Enrichment: res.Enrichment}, nil
+}
+
+func (p *nationalizeProvider) LookupBatch(ctx context.Context, names []string) ([]Answer, error) {
+	res, err := p.api.GetBatch(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Answer, 0, len(res))
+	for _, r := range res {
+		ret = append(ret, Answer{Value: r.Country, Enrichment: r.Enrichment})
+	}
+	return ret, nil
+}
@@ -0,0 +1,204 @@
+package quota
+
+import (
+	"dataservice/internal/errs"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultBurst = 10
+	// window is how long a daily quota lasts when the provider does not
+	// report when it resets.
+	window = 24 * time.Hour
+)
+
+// Config sets a provider's budgets: PerSecond requests a second in bursts
+// of up to Burst, and PerDay requests a day. Zero budgets are not enforced.
+// A batched request counts once per name.
+type Config struct {
+	Provider  string
+	PerSecond float64
+	Burst     int
+	PerDay    int
+}
+
+// Names is how many names can be sent within d without going over the
+// budgets, -1 when they are not enforced. The burst is left as slack for
+// the requests themselves to complete.
+func (c Config) Names(d time.Duration) int {
+	ret := -1
+	if c.PerSecond > 0 {
+		ret = max(1, int(c.PerSecond*d.Seconds()))
+	}
+	if c.PerDay > 0 && (ret < 0 || c.PerDay < ret) {
+		ret = c.PerDay
+	}
+	return ret
+}
+
+type Dependencies struct {
+	Next http.RoundTripper
+	Log  *zap.Logger
+}
+
+type Stats struct {
+	Provider string `json:"provider"`
+	// Remaining is the daily quota left, -1 when unknown.
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// Error rejects a request because the provider's daily quota is used up.
+type Error struct {
+	Provider string
+	Reset    time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: quota exhausted, resets in %s", e.Provider, e.Reset.Round(time.Second))
+}
+
+// Transport keeps the requests to a provider within its quota. Requests
+// over the per-second budget wait for their turn, those over the daily one
+// fail with an *Error until it resets. The daily quota left follows the
+// provider's X-Rate-Limit headers.
+type Transport struct {
+	cfg     Config
+	deps    Dependencies
+	log     *zap.Logger
+	limiter *rate.Limiter
+
+	mu        sync.Mutex
+	remaining int
+	resetAt   time.Time
+
+	now func() time.Time
+}
+
+func New(cfg Config, deps Dependencies) *Transport {
+	if cfg.Burst == 0 {
+		cfg.Burst = defaultBurst
+	}
+	if deps.Next == nil {
+		deps.Next = http.DefaultTransport
+	}
+
+	limit := rate.Inf
+	if cfg.PerSecond > 0 {
+		limit = rate.Limit(cfg.PerSecond)
+	}
+
+	t := &Transport{
+		cfg:     cfg,
+		deps:    deps,
+		log:     deps.Log.Named("quota").With(zap.String("provider", cfg.Provider)),
+		limiter: rate.NewLimiter(limit, cfg.Burst),
+		now:     time.Now,
+	}
+	t.reset()
+	return t
+}
+
+func (t *Transport) Stats() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return Stats{
+		Provider:  t.cfg.Provider,
+		Remaining: t.remaining,
+		ResetAt:   t.resetAt,
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	n := cost(req)
+	if err := t.reserve(n); err != nil {
+		return nil, err
+	}
+
+	if err := t.limiter.WaitN(req.Context(), min(n, t.cfg.Burst)); err != nil {
+		t.release(n)
+		return nil, errs.E(errs.KindTimeout, fmt.Errorf("%s: rate limit: %w", t.cfg.Provider, err))
+	}
+
+	resp, err := t.deps.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	t.update(resp)
+	return resp, nil
+}
+
+// cost is the number of names looked up by req.
+func cost(req *http.Request) int {
+	if n := len(req.URL.Query()["name[]"]); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// reset starts a new daily window. Must be called with mu held.
+func (t *Transport) reset() {
+	t.remaining = -1
+	if t.cfg.PerDay > 0 {
+		t.remaining = t.cfg.PerDay
+	}
+	t.resetAt = t.now().Add(window)
+}
+
+func (t *Transport) reserve(n int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if !now.Before(t.resetAt) {
+		t.reset()
+	}
+
+	if t.remaining < 0 {
+		return nil
+	}
+	if t.remaining < n {
+		t.log.Warn("quota exhausted", zap.Time("reset_at", t.resetAt))
+		return errs.E(errs.KindUnavailable, &Error{
+			Provider: t.cfg.Provider,
+			Reset:    t.resetAt.Sub(now),
+		})
+	}
+	t.remaining -= n
+	return nil
+}
+
+func (t *Transport) release(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.remaining >= 0 {
+		t.remaining += n
+	}
+}
+
+// update takes the quota left from the X-Rate-Limit-Remaining and
+// X-Rate-Limit-Reset headers of resp.
+func (t *Transport) update(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Remaining"))
+	if err != nil || remaining < 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.remaining = remaining
+	if reset, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Reset")); err == nil && reset > 0 {
+		t.resetAt = t.now().Add(time.Duration(reset) * time.Second)
+	}
+}
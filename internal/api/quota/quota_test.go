@@ -0,0 +1,164 @@
+package quota
+
+import (
+	"context"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/errs"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+type testServer struct {
+	url    string
+	client *http.Client
+	calls  *atomic.Int32
+	clock  *clock
+	quota  *Transport
+}
+
+func newTestServer(t *testing.T, cfg Config, header http.Header) *testServer {
+	calls := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	clk := &clock{now: time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC)}
+	cfg.Provider = "agify"
+	q := New(cfg, Dependencies{Next: srv.Client().Transport, Log: zap.NewNop()})
+	q.now = clk.Now
+	q.reset()
+
+	return &testServer{
+		url:    srv.URL,
+		client: &http.Client{Transport: q},
+		calls:  calls,
+		clock:  clk,
+		quota:  q,
+	}
+}
+
+func (s *testServer) get(ctx context.Context, query string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+query, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestQuotaPerDay(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t, Config{PerDay: 12}, nil)
+
+	require.NoError(t, srv.get(ctx, "/?name=Dmitry"))
+	require.NoError(t, srv.get(ctx, "/?name[]=Anna&name[]=Boris&name[]=Ivan"))
+	require.Equal(t, 8, srv.quota.Stats().Remaining)
+
+	err := srv.get(ctx, "/?name[]=a&name[]=b&name[]=c&name[]=d&name[]=e&name[]=f&name[]=g&name[]=h&name[]=i")
+	require.Equal(t, errs.KindUnavailable, errs.KindOf(err))
+
+	var qe *Error
+	require.True(t, errors.As(err, &qe))
+	require.Equal(t, "agify", qe.Provider)
+	require.Equal(t, 24*time.Hour, qe.Reset)
+	require.Equal(t, int32(2), srv.calls.Load())
+
+	srv.clock.now = srv.clock.now.Add(24 * time.Hour)
+	require.NoError(t, srv.get(ctx, "/?name=Dmitry"))
+	require.Equal(t, 11, srv.quota.Stats().Remaining)
+}
+
+func TestQuotaHeaders(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t, Config{}, http.Header{
+		"X-Rate-Limit-Remaining": {"0"},
+		"X-Rate-Limit-Reset":     {"3600"},
+	})
+
+	require.NoError(t, srv.get(ctx, "/?name=Dmitry"))
+	require.Equal(t, Stats{
+		Provider:  "agify",
+		Remaining: 0,
+		ResetAt:   srv.clock.now.Add(time.Hour),
+	}, srv.quota.Stats())
+
+	var qe *Error
+	require.True(t, errors.As(srv.get(ctx, "/?name=Dmitry"), &qe))
+	require.Equal(t, time.Hour, qe.Reset)
+	require.Equal(t, int32(1), srv.calls.Load())
+
+	srv.clock.now = srv.clock.now.Add(time.Hour)
+	require.NoError(t, srv.get(ctx, "/?name=Dmitry"))
+}
+
+func TestQuotaPerSecond(t *testing.T) {
+	srv := newTestServer(t, Config{PerSecond: 1, Burst: 2}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, srv.get(ctx, "/?name=Dmitry"))
+	require.NoError(t, srv.get(ctx, "/?name=Dmitry"))
+
+	err := srv.get(ctx, "/?name=Dmitry")
+	require.Equal(t, errs.KindTimeout, errs.KindOf(err))
+	require.Equal(t, int32(2), srv.calls.Load())
+}
+
+func TestQuotaNames(t *testing.T) {
+	require.Equal(t, -1, Config{}.Names(time.Second))
+	require.Equal(t, 50, Config{PerSecond: 5}.Names(10*time.Second))
+	require.Equal(t, 1, Config{PerSecond: 0.1}.Names(time.Second))
+	require.Equal(t, 20, Config{PerSecond: 5, PerDay: 20}.Names(10*time.Second))
+	require.Equal(t, 20, Config{PerDay: 20}.Names(10*time.Second))
+}
+
+func TestQuotaFullBatch(t *testing.T) {
+	t.Parallel()
+
+	// The quota in .env against the manager's batch timeout.
+	const timeout = 10 * time.Second
+	cfg := Config{PerSecond: 10, PerDay: 100}
+	names := cfg.Names(timeout)
+	require.Equal(t, 100, names)
+
+	srv := newTestServer(t, cfg, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Sent the way the providers' clients batch names.
+	for start := 0; start < names; start += upstream.MaxBatch {
+		query := url.Values{}
+		for i := start; i < min(start+upstream.MaxBatch, names); i++ {
+			query.Add("name[]", fmt.Sprintf("Name%d", i))
+		}
+		require.NoError(t, srv.get(ctx, "/?"+query.Encode()))
+	}
+	require.Equal(t, 0, srv.quota.Stats().Remaining)
+	require.Equal(t, int32(10), srv.calls.Load())
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"dataservice/internal/schema"
+	"fmt"
+)
+
+// Registry holds the available providers by name.
+type Registry struct {
+	providers map[string]Provider
+	names     []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: map[string]Provider{},
+	}
+}
+
+// Register adds p, which must fill one of schema.Attributes.
+func (r *Registry) Register(p Provider) error {
+	if _, ok := r.providers[p.Name()]; ok {
+		return fmt.Errorf("provider %q is already registered", p.Name())
+	}
+	if _, ok := schema.LookupAttribute(p.Attribute()); !ok {
+		return fmt.Errorf("provider %q fills unknown attribute %q", p.Name(), p.Attribute())
+	}
+
+	r.providers[p.Name()] = p
+	r.names = append(r.names, p.Name())
+	return nil
+}
+
+// Names lists the registered providers in the order of registration.
+func (r *Registry) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Select returns the named providers, which must fill distinct attributes.
+func (r *Registry) Select(names []string) ([]Provider, error) {
+	ret := make([]Provider, 0, len(names))
+	attrs := map[string]string{}
+	for _, name := range names {
+		p, ok := r.providers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q", name)
+		}
+
+		if other, ok := attrs[p.Attribute()]; ok {
+			return nil, fmt.Errorf("providers %q and %q both fill %s", other, name, p.Attribute())
+		}
+		attrs[p.Attribute()] = name
+
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
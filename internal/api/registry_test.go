@@ -0,0 +1,43 @@
+package api
+
+import (
+	"dataservice/internal/schema"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestProvider(ctrl *gomock.Controller, name, attr string) *MockProvider {
+	p := NewMockProvider(ctrl)
+	p.EXPECT().Name().Return(name).AnyTimes()
+	p.EXPECT().Attribute().Return(attr).AnyTimes()
+	return p
+}
+
+func TestRegistry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := NewAPIMock(ctrl)
+	table := newTestProvider(ctrl, "table", schema.AttrCountry)
+
+	r := NewRegistry()
+	for _, p := range append(mock.Providers(), table) {
+		require.NoError(t, r.Register(p))
+	}
+	require.Error(t, r.Register(table))
+	require.ErrorContains(t, r.Register(newTestProvider(ctrl, "hunter", "email")),
+		`provider "hunter" fills unknown attribute "email"`)
+	require.Equal(t, []string{"agify", "genderize", "nationalize", "table"}, r.Names())
+
+	providers, err := r.Select([]string{"table", "agify"})
+	require.NoError(t, err)
+	require.Len(t, providers, 2)
+	require.Equal(t, "table", providers[0].Name())
+	require.Equal(t, schema.AttrAge, providers[1].Attribute())
+
+	_, err = r.Select([]string{"agify", "unknown"})
+	require.ErrorContains(t, err, `unknown provider "unknown"`)
+
+	_, err = r.Select([]string{"nationalize", "agify", "table"})
+	require.ErrorContains(t, err, `providers "nationalize" and "table" both fill country`)
+}
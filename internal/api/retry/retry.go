@@ -0,0 +1,174 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAttempts  = 3
+	defaultBaseDelay = 100 * time.Millisecond
+	defaultMaxDelay  = time.Second
+)
+
+// Config sets up the backoff: attempt n waits BaseDelay*2^(n-1), capped by
+// MaxDelay, of which a random Jitter fraction is subtracted.
+type Config struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Jitter    float64
+}
+
+type Dependencies struct {
+	Next http.RoundTripper
+	Log  *zap.Logger
+}
+
+// Transport retries idempotent requests failing with connection errors,
+// 5xx or 429 responses. It never waits past the request context deadline.
+type Transport struct {
+	cfg  Config
+	deps Dependencies
+	log  *zap.Logger
+}
+
+func New(cfg Config, deps Dependencies) *Transport {
+	if cfg.Attempts == 0 {
+		cfg.Attempts = defaultAttempts
+	}
+	if cfg.BaseDelay == 0 {
+		cfg.BaseDelay = defaultBaseDelay
+	}
+	if cfg.MaxDelay == 0 {
+		cfg.MaxDelay = defaultMaxDelay
+	}
+	if deps.Next == nil {
+		deps.Next = http.DefaultTransport
+	}
+
+	return &Transport{
+		cfg:  cfg,
+		deps: deps,
+		log:  deps.Log.Named("retry"),
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !idempotent(req) {
+		return t.deps.Next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.deps.Next.RoundTrip(req)
+		if attempt == t.cfg.Attempts || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				delay = after
+			}
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		t.log.Debug("retrying request",
+			zap.String("host", req.URL.Host),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+func (t *Transport) backoff(attempt int) time.Duration {
+	delay := t.cfg.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > t.cfg.MaxDelay {
+		delay = t.cfg.MaxDelay
+	}
+	return delay - time.Duration(t.cfg.Jitter*rand.Float64()*float64(delay))
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr) ||
+			errors.Is(err, io.EOF) ||
+			errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, syscall.ECONNREFUSED)
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented)
+}
+
+// retryAfter parses the Retry-After header given either in seconds or as
+// an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		delay := time.Until(at)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
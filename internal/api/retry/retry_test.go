@@ -0,0 +1,168 @@
+package retry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestClient() *http.Client {
+	return &http.Client{
+		Transport: New(
+			Config{
+				Attempts:  3,
+				BaseDelay: time.Millisecond,
+				MaxDelay:  5 * time.Millisecond,
+				Jitter:    0.5,
+			},
+			Dependencies{
+				Log: zap.NewNop(),
+			},
+		),
+	}
+}
+
+func serve(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n > len(statuses) {
+			n = len(statuses)
+		}
+		w.WriteHeader(statuses[n-1])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls
+}
+
+func TestRetryTransient(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		statuses []int
+		status   int
+		calls    int32
+	}{
+		{"success", []int{200}, 200, 1},
+		{"5xx then success", []int{503, 500, 200}, 200, 3},
+		{"attempts exhausted", []int{502}, 502, 3},
+		{"429 then success", []int{429, 200}, 200, 2},
+		{"client error", []int{400}, 400, 1},
+		{"not implemented", []int{501}, 501, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, calls := serve(t, tc.statuses...)
+
+			resp, err := newTestClient().Get(srv.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, tc.status, resp.StatusCode)
+			require.Equal(t, tc.calls, calls.Load())
+		})
+	}
+}
+
+func TestRetryNotIdempotent(t *testing.T) {
+	srv, calls := serve(t, 503, 200)
+
+	resp, err := newTestClient().Post(srv.URL, "text/plain", strings.NewReader("body"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryConnectionError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	var calls atomic.Int32
+	client := newTestClient()
+	client.Transport.(*Transport).deps.Next = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	_, err := client.Get(srv.URL)
+	require.Error(t, err)
+	require.Equal(t, int32(3), calls.Load())
+}
+
+func TestRetryAfterDeadline(t *testing.T) {
+	calls := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	resp, err := newTestClient().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, int32(1), calls.Load())
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		delay  time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	} {
+		resp := &http.Response{Header: http.Header{}}
+		if tc.header != "" {
+			resp.Header.Set("Retry-After", tc.header)
+		}
+
+		delay, ok := retryAfter(resp)
+		require.Equal(t, tc.ok, ok, tc.header)
+		require.Equal(t, tc.delay, delay, tc.header)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tr := New(
+		Config{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.5},
+		Dependencies{Log: zap.NewNop()},
+	)
+
+	for attempt, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		delay := tr.backoff(attempt + 1)
+		require.LessOrEqual(t, delay, max)
+		require.GreaterOrEqual(t, delay, max/2)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,125 @@
+package table
+
+import (
+	"context"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/genderapi"
+	"dataservice/internal/api/nationalizeapi"
+	"dataservice/internal/schema"
+	"time"
+)
+
+// enrichment describes an answer of the table: a count of one for a known
+// value, zero for an unknown one, and no confidence.
+func enrichment(provider string, known bool) schema.Enrichment {
+	ret := schema.Enrichment{
+		Provider:  provider,
+		FetchedAt: time.Now(),
+	}
+	if known {
+		ret.Count = 1
+	}
+	return ret
+}
+
+type ageAPI struct {
+	provider string
+	table    *Table
+}
+
+// NewAgeAPI answers age lookups from t. The table is not localized, so
+// GetLocalized returns the global answer.
+func NewAgeAPI(provider string, t *Table) ageapi.AgeAPI {
+	return &ageAPI{
+		provider: provider,
+		table:    t,
+	}
+}
+
+func (a *ageAPI) Get(_ context.Context, name string) (ageapi.Result, error) {
+	r, _ := a.table.lookup(name)
+	return ageapi.Result{Age: r.age, Enrichment: enrichment(a.provider, r.age != 0)}, nil
+}
+
+func (a *ageAPI) GetLocalized(ctx context.Context, name, _ string) (ageapi.Result, error) {
+	return a.Get(ctx, name)
+}
+
+func (a *ageAPI) GetBatch(ctx context.Context, names []string) ([]ageapi.Result, error) {
+	ret := make([]ageapi.Result, 0, len(names))
+	for _, name := range names {
+		res, _ := a.Get(ctx, name)
+		ret = append(ret, res)
+	}
+	return ret, nil
+}
+
+type genderAPI struct {
+	provider string
+	table    *Table
+}
+
+// NewGenderAPI answers gender lookups from t. The table is not localized,
+// so GetLocalized returns the global answer.
+func NewGenderAPI(provider string, t *Table) genderapi.GenderAPI {
+	return &genderAPI{
+		provider: provider,
+		table:    t,
+	}
+}
+
+func (g *genderAPI) Get(_ context.Context, name string) (genderapi.Result, error) {
+	r, _ := g.table.lookup(name)
+	return genderapi.Result{Gender: r.gender, Enrichment: enrichment(g.provider, r.gender != "")}, nil
+}
+
+func (g *genderAPI) GetLocalized(ctx context.Context, name, _ string) (genderapi.Result, error) {
+	return g.Get(ctx, name)
+}
+
+func (g *genderAPI) GetBatch(ctx context.Context, names []string) ([]genderapi.Result, error) {
+	ret := make([]genderapi.Result, 0, len(names))
+	for _, name := range names {
+		res, _ := g.Get(ctx, name)
+		ret = append(ret, res)
+	}
+	return ret, nil
+}
+
+type nationalizeAPI struct {
+	provider string
+	table    *Table
+}
+
+// NewNationalizeAPI answers country lookups from t, which holds a single
+// country per name.
+func NewNationalizeAPI(provider string, t *Table) nationalizeapi.NationalizeAPI {
+	return &nationalizeAPI{
+		provider: provider,
+		table:    t,
+	}
+}
+
+func (n *nationalizeAPI) Get(_ context.Context, name string) (nationalizeapi.Result, error) {
+	r, _ := n.table.lookup(name)
+	if r.country == "" {
+		return nationalizeapi.Result{
+			Country:    schema.UnknownCountry,
+			Enrichment: enrichment(n.provider, false),
+		}, nil
+	}
+	return nationalizeapi.Result{Country: r.country, Enrichment: enrichment(n.provider, true)}, nil
+}
+
+func (n *nationalizeAPI) GetBatch(ctx context.Context, names []string) ([]nationalizeapi.Result, error) {
+	ret := make([]nationalizeapi.Result, 0, len(names))
+	for _, name := range names {
+		res, _ := n.Get(ctx, name)
+		ret = append(ret, res)
+	}
+	return ret, nil
+}
+
+func (n *nationalizeAPI) Rank(ctx context.Context, name string) (nationalizeapi.Result, error) {
+	return n.Get(ctx, name)
+}
@@ -0,0 +1,104 @@
+package table
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var header = []string{"name", "age", "gender", "country"}
+
+type row struct {
+	age     int
+	gender  string
+	country string
+}
+
+// Table answers lookups from a fixed list of names, as a last resort when
+// the providers cannot.
+type Table struct {
+	rows map[string]row
+}
+
+// Load reads a table from a CSV file, see Parse.
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Parse reads CSV with a name,age,gender,country header. Names match
+// regardless of case and empty cells leave the attribute unknown.
+func Parse(r io.Reader) (*Table, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(header)
+
+	first, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("missing header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Equal(first, header) {
+		return nil, fmt.Errorf("header must be %s", strings.Join(header, ","))
+	}
+
+	t := &Table{rows: map[string]row{}}
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return t, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		name := strings.ToLower(rec[0])
+		if name == "" {
+			return nil, fmt.Errorf("line %d: empty name", line)
+		}
+		if _, ok := t.rows[name]; ok {
+			return nil, fmt.Errorf("line %d: duplicate name %q", line, rec[0])
+		}
+
+		r := row{gender: rec[2], country: rec[3]}
+		if rec[1] != "" {
+			r.age, err = strconv.Atoi(rec[1])
+			if err != nil || r.age < 0 {
+				return nil, fmt.Errorf("line %d: invalid age %q", line, rec[1])
+			}
+		}
+		if r.gender != "" && r.gender != "male" && r.gender != "female" {
+			return nil, fmt.Errorf("line %d: invalid gender %q", line, r.gender)
+		}
+		if r.country != "" && !isCountryCode(r.country) {
+			return nil, fmt.Errorf("line %d: invalid country %q", line, r.country)
+		}
+		t.rows[name] = r
+	}
+}
+
+// isCountryCode reports whether s looks like an ISO 3166-1 alpha-2 code.
+func isCountryCode(s string) bool {
+	return len(s) == 2 && 'A' <= s[0] && s[0] <= 'Z' && 'A' <= s[1] && s[1] <= 'Z'
+}
+
+func (t *Table) lookup(name string) (row, bool) {
+	r, ok := t.rows[strings.ToLower(name)]
+	return r, ok
+}
@@ -0,0 +1,64 @@
+package table
+
+import (
+	"context"
+	"dataservice/internal/schema"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const names = `name,age,gender,country
+Dmitry,42,male,RU
+Anna,,female,
+`
+
+func TestTable(t *testing.T) {
+	ctx := context.Background()
+	tbl, err := Parse(strings.NewReader(names))
+	require.NoError(t, err)
+
+	age, err := NewAgeAPI("names", tbl).GetLocalized(ctx, "dmitry", "UA")
+	require.NoError(t, err)
+	require.Equal(t, 42, age.Age)
+	require.Equal(t, "names", age.Provider)
+	require.Equal(t, 1, age.Count)
+	require.Empty(t, age.CountryID)
+
+	genders, err := NewGenderAPI("names", tbl).GetBatch(ctx, []string{"Anna", "Ivan"})
+	require.NoError(t, err)
+	require.Len(t, genders, 2)
+	require.Equal(t, "female", genders[0].Gender)
+	require.Equal(t, "", genders[1].Gender)
+	require.Equal(t, 0, genders[1].Count)
+
+	nationalize := NewNationalizeAPI("names", tbl)
+	country, err := nationalize.Get(ctx, "Dmitry")
+	require.NoError(t, err)
+	require.Equal(t, "RU", country.Country)
+
+	country, err = nationalize.Rank(ctx, "Anna")
+	require.NoError(t, err)
+	require.Equal(t, schema.UnknownCountry, country.Country)
+	require.Equal(t, 0, country.Count)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		err  string
+	}{
+		{"", "missing header"},
+		{"name,age\n", "wrong number of fields"},
+		{"name,gender,age,country\n", "header must be name,age,gender,country"},
+		{"name,age,gender,country\n,1,male,RU\n", "line 2: empty name"},
+		{"name,age,gender,country\nAnna,1,,\nanna,2,,\n", `line 3: duplicate name "anna"`},
+		{"name,age,gender,country\nAnna,-1,,\n", `line 2: invalid age "-1"`},
+		{"name,age,gender,country\nAnna,,f,\n", `line 2: invalid gender "f"`},
+		{"name,age,gender,country\nAnna,,,Russia\n", `line 2: invalid country "Russia"`},
+	} {
+		_, err := Parse(strings.NewReader(tc.data))
+		require.ErrorContains(t, err, tc.err, tc.data)
+	}
+}
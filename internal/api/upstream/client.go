@@ -0,0 +1,109 @@
+package upstream
+
+import (
+	"context"
+	"dataservice/internal/api/apikey"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+// MaxBatch is the number of names the providers accept per request.
+const MaxBatch = 10
+
+type Config struct {
+	Provider string
+	URI      string
+	// APIKey is sent with every request when set.
+	APIKey string
+}
+
+type Dependencies struct {
+	Client *http.Client
+	Log    *zap.Logger
+}
+
+// Client queries a provider's API.
+type Client struct {
+	cfg  Config
+	deps Dependencies
+	log  *zap.Logger
+}
+
+func NewClient(cfg Config, deps Dependencies) *Client {
+	return &Client{
+		cfg:  cfg,
+		deps: deps,
+		log:  deps.Log.Named(cfg.Provider),
+	}
+}
+
+// Do queries the provider and decodes the response into dst.
+func (c *Client) Do(ctx context.Context, query url.Values, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.URI, nil)
+	if err != nil {
+		c.log.Error("failed to create http request", zap.Error(err))
+		return err
+	}
+
+	q := req.URL.Query()
+	for key, values := range query {
+		q[key] = append(q[key], values...)
+	}
+	if c.cfg.APIKey != "" {
+		q.Set(apikey.Param, c.cfg.APIKey)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.deps.Client.Do(req)
+	if err != nil {
+		err = apikey.Redact(err, c.cfg.APIKey)
+		c.log.Error("failed to do http request", zap.Error(err))
+		return err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.log.Error("failed to read response body", zap.Error(err))
+		return err
+	}
+
+	if err := apikey.Redact(Check(c.cfg.Provider, resp, body), c.cfg.APIKey); err != nil {
+		c.log.Error("provider returned an error", zap.Error(err))
+		return err
+	}
+
+	if err := json.Unmarshal(body, dst); err != nil {
+		c.log.Error("failed to unmarshal response", zap.Error(err))
+		return err
+	}
+
+	c.log.Debug("success request", zap.Any("resp", dst))
+	return nil
+}
+
+// GetBatch queries the names MaxBatch at a time, decoding a T per name in
+// the order of names.
+func GetBatch[T any](ctx context.Context, c *Client, names []string) ([]T, error) {
+	ret := make([]T, 0, len(names))
+	for start := 0; start < len(names); start += MaxBatch {
+		chunk := names[start:min(start+MaxBatch, len(names))]
+
+		res := make([]T, 0, len(chunk))
+		if err := c.Do(ctx, url.Values{"name[]": chunk}, &res); err != nil {
+			return nil, err
+		}
+		if len(res) != len(chunk) {
+			err := fmt.Errorf("%s: got %d results for %d names", c.cfg.Provider, len(res), len(chunk))
+			c.log.Error("unexpected batch response", zap.Error(err))
+			return nil, err
+		}
+		ret = append(ret, res...)
+	}
+	return ret, nil
+}
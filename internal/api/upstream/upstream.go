@@ -0,0 +1,72 @@
+package upstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error is a non-2xx response of an enrichment provider.
+type Error struct {
+	Provider string
+	Status   int
+	Message  string
+	// RateLimitRemaining is the number of requests left in the current
+	// window, -1 when the provider did not report it.
+	RateLimitRemaining int
+	// RateLimitReset is the time until the window resets, zero when the
+	// provider did not report it.
+	RateLimitReset time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s: unexpected status %d", e.Provider, e.Status)
+	}
+	return fmt.Sprintf("%s: unexpected status %d: %s", e.Provider, e.Status, e.Message)
+}
+
+func (e *Error) RateLimited() bool {
+	return e.Status == http.StatusTooManyRequests
+}
+
+// Temporary reports whether the provider itself is failing, as opposed to
+// rejecting this particular request.
+func (e *Error) Temporary() bool {
+	return e.RateLimited() || e.Status >= http.StatusInternalServerError
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// Check returns an *Error for a non-2xx response, using the provider's
+// error field from body as the message.
+func Check(provider string, resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	e := &Error{
+		Provider:           provider,
+		Status:             resp.StatusCode,
+		RateLimitRemaining: -1,
+	}
+
+	eb := errorBody{}
+	if err := json.Unmarshal(body, &eb); err == nil && eb.Error != "" {
+		e.Message = eb.Error
+	} else {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+
+	if v, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Remaining")); err == nil {
+		e.RateLimitRemaining = v
+	}
+	if v, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Reset")); err == nil && v > 0 {
+		e.RateLimitReset = time.Duration(v) * time.Second
+	}
+	return e
+}
@@ -0,0 +1,84 @@
+package upstream
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		header http.Header
+		body   string
+		err    *Error
+	}{
+		{
+			name:   "success",
+			status: http.StatusOK,
+			body:   `{"count":1,"name":"Dmitry","age":42}`,
+		},
+		{
+			name:   "rate limited",
+			status: http.StatusTooManyRequests,
+			header: http.Header{
+				"X-Rate-Limit-Remaining": {"0"},
+				"X-Rate-Limit-Reset":     {"3600"},
+			},
+			body: `{"error":"Request limit reached"}`,
+			err: &Error{
+				Provider:           "agify",
+				Status:             http.StatusTooManyRequests,
+				Message:            "Request limit reached",
+				RateLimitRemaining: 0,
+				RateLimitReset:     time.Hour,
+			},
+		},
+		{
+			name:   "invalid name",
+			status: http.StatusUnprocessableEntity,
+			body:   `{"error":"Invalid 'name' parameter"}`,
+			err: &Error{
+				Provider:           "agify",
+				Status:             http.StatusUnprocessableEntity,
+				Message:            "Invalid 'name' parameter",
+				RateLimitRemaining: -1,
+			},
+		},
+		{
+			name:   "not json",
+			status: http.StatusBadGateway,
+			body:   `<html>bad gateway</html>`,
+			err: &Error{
+				Provider:           "agify",
+				Status:             http.StatusBadGateway,
+				Message:            "Bad Gateway",
+				RateLimitRemaining: -1,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tc.status, Header: tc.header}
+			if resp.Header == nil {
+				resp.Header = http.Header{}
+			}
+
+			err := Check("agify", resp, []byte(tc.body))
+			if tc.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func TestTemporary(t *testing.T) {
+	require.True(t, (&Error{Status: http.StatusTooManyRequests}).Temporary())
+	require.True(t, (&Error{Status: http.StatusServiceUnavailable}).Temporary())
+	require.False(t, (&Error{Status: http.StatusUnauthorized}).Temporary())
+	require.False(t, (&Error{Status: http.StatusUnprocessableEntity}).Temporary())
+}
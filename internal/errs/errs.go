@@ -0,0 +1,84 @@
+package errs
+
+import (
+	"errors"
+)
+
+type Kind uint8
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindValidation
+	KindUpstream
+	KindUnavailable
+	KindConflict
+	KindTimeout
+	// KindCanceled is an operation abandoned by its caller, which is not a
+	// failure of the service.
+	KindCanceled
+)
+
+var kindCodes = map[Kind]string{
+	KindInternal:    "internal",
+	KindNotFound:    "not_found",
+	KindValidation:  "validation_failed",
+	KindUpstream:    "upstream_failed",
+	KindUnavailable: "unavailable",
+	KindConflict:    "conflict",
+	KindTimeout:     "timeout",
+	KindCanceled:    "canceled",
+}
+
+// Code is the stable machine-readable name of the kind reported to clients.
+func (k Kind) Code() string {
+	if code, ok := kindCodes[k]; ok {
+		return code
+	}
+	return kindCodes[KindInternal]
+}
+
+func (k Kind) String() string {
+	return k.Code()
+}
+
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// E attaches kind to err. Errors that already carry a kind keep it.
+func E(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	return &Error{Kind: kind, Err: err}
+}
+
+func New(kind Kind, msg string) error {
+	return &Error{Kind: kind, Err: errors.New(msg)}
+}
+
+// KindOf returns the kind of the first classified error in the chain,
+// KindInternal if there is none.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
@@ -3,21 +3,63 @@ package manager
 import (
 	"context"
 	"dataservice/internal/api"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/errs"
 	"dataservice/internal/schema"
 	"dataservice/internal/userdb"
 	"dataservice/internal/utils"
+	"dataservice/internal/validation"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"go.uber.org/zap"
 )
 
+// Policy decides what happens when some of the enrichment lookups fail.
+type Policy uint8
+
+const (
+	// PolicyStrict rejects the person.
+	PolicyStrict Policy = iota
+	// PolicyBestEffort stores the person with the failed attributes left
+	// empty and listed as pending, to be filled in later.
+	PolicyBestEffort
+)
+
+func ParsePolicy(s string) (Policy, error) {
+	switch s {
+	case "", "strict":
+		return PolicyStrict, nil
+	case "best-effort":
+		return PolicyBestEffort, nil
+	}
+	return PolicyStrict, fmt.Errorf("unknown enrichment policy: %s", s)
+}
+
 type Config struct {
 	Timeout time.Duration
+	// BatchTimeout bounds the lookups of AddPersonInfoBatch, Timeout when
+	// unset.
+	BatchTimeout time.Duration
+	// MaxBatch caps the persons AddPersonInfoBatch takes at once, so that
+	// their lookups fit the providers' quotas. Zero means no cap.
+	MaxBatch int
+	Policy   Policy
+	// Localize looks up age and gender for the person's nationality, using
+	// the global answers when the localized ones rest on fewer than
+	// MinSamples people.
+	Localize   bool
+	MinSamples int
 }
 
 type Dependencies struct {
-	API api.API
-	DB  userdb.DB
+	// Providers fill in the attributes, one provider per attribute.
+	Providers []api.Provider
+	DB        userdb.DB
 
 	Log *zap.Logger
 }
@@ -25,73 +67,249 @@ type Dependencies struct {
 type Manager struct {
 	cfg  Config
 	deps Dependencies
+
+	now func() time.Time
 }
 
 func New(cfg Config, deps Dependencies) *Manager {
+	if cfg.MinSamples == 0 {
+		cfg.MinSamples = api.DefaultMinSamples
+	}
+
 	return &Manager{
 		cfg:  cfg,
 		deps: deps,
+		now:  time.Now,
 	}
 }
 
+// upstreamKind reports a provider's rate limit as a temporary unavailability
+// and any other enrichment failure as an upstream error.
+func upstreamKind(err error) errs.Kind {
+	var ue *upstream.Error
+	if errors.As(err, &ue) && ue.RateLimited() {
+		return errs.KindUnavailable
+	}
+	return errs.KindUpstream
+}
+
+// answer is a provider's reply to a lookup.
+type answer struct {
+	api.Answer
+	err error
+}
+
+// given reports whether the caller supplied attr, which is then not looked
+// up.
+func given(req schema.PutRequest, attr string) bool {
+	return attr == schema.AttrCountry && req.Country != ""
+}
+
 func (m *Manager) enrichMessage(ctx context.Context, req schema.PutRequest) (schema.PersonInfo, error) {
-	var (
-		age         int
-		gender      string
-		nationalize string
-	)
+	q := api.Query{Name: req.Name, MinSamples: m.cfg.MinSamples}
+	if m.cfg.Localize {
+		q.CountryID = req.Country
+	}
 
-	err := utils.ParallelRequest(ctx, m.cfg.Timeout,
-		func(ctx context.Context) error {
-			var err error
-			age, err = m.deps.API.AgeAPI().Get(ctx, req.Name)
-			return err
-		},
-		func(ctx context.Context) error {
-			var err error
-			gender, err = m.deps.API.GenderAPI().Get(ctx, req.Name)
-			return err
-		},
-		func(ctx context.Context) error {
-			var err error
-			nationalize, err = m.deps.API.NationalizeAPI().Get(ctx, req.Name)
+	answers := make([]answer, len(m.deps.Providers))
+	// The others are localized to the nationality, so it is looked up first.
+	var first, rest []utils.RequestFunc
+	for i, p := range m.deps.Providers {
+		if given(req, p.Attribute()) {
+			continue
+		}
+
+		i, p := i, p
+		lookup := func(ctx context.Context) error {
+			a, err := p.Lookup(ctx, q)
+			answers[i] = answer{Answer: a, err: err}
 			return err
-		},
+		}
+		if m.cfg.Localize && p.Attribute() == schema.AttrCountry {
+			first = append(first, lookup)
+		} else {
+			rest = append(rest, lookup)
+		}
+	}
+
+	err := utils.ParallelRequest(ctx, m.cfg.Timeout, first...)
+	if m.cfg.Localize && q.CountryID == "" {
+		q.CountryID = m.country(answers)
+	}
+	if err == nil || m.cfg.Policy != PolicyStrict {
+		if rerr := utils.ParallelRequest(ctx, m.cfg.Timeout, rest...); rerr != nil {
+			err = multierror.Append(err, rerr)
+		}
+	}
+	if err != nil && m.cfg.Policy == PolicyStrict {
+		m.deps.Log.Error("failed to API reqeusts", zap.Error(err))
+		return schema.PersonInfo{}, errs.E(upstreamKind(err), err)
+	}
+
+	return m.person(req, answers), nil
+}
+
+// country returns the nationality found in answers, if any.
+func (m *Manager) country(answers []answer) string {
+	for i, p := range m.deps.Providers {
+		if p.Attribute() != schema.AttrCountry || answers[i].err != nil {
+			continue
+		}
+		if country, ok := answers[i].Value.(string); ok {
+			return country
+		}
+	}
+	return ""
+}
+
+// enrichBatch looks up each distinct name once, with a batched call per
+// provider. Lookups are never localized, which would split the batches by
+// country.
+func (m *Manager) enrichBatch(ctx context.Context, reqs []schema.PutRequest) ([]schema.PersonInfo, error) {
+	timeout := m.cfg.BatchTimeout
+	if timeout == 0 {
+		timeout = m.cfg.Timeout
+	}
+
+	var (
+		indexes  = make([]map[string]int, len(m.deps.Providers))
+		answers  = make([][]api.Answer, len(m.deps.Providers))
+		failures = make([]error, len(m.deps.Providers))
+		lookups  = make([]utils.RequestFunc, 0, len(m.deps.Providers))
 	)
-	if err != nil {
+	for i, p := range m.deps.Providers {
+		// Attributes supplied by the caller are only looked up for the others.
+		names, index := distinct(slices.DeleteFunc(slices.Clone(reqs), func(req schema.PutRequest) bool {
+			return given(req, p.Attribute())
+		}))
+		indexes[i] = index
+
+		i, p := i, p
+		lookups = append(lookups, func(ctx context.Context) error {
+			if len(names) == 0 {
+				return nil
+			}
+			answers[i], failures[i] = p.LookupBatch(ctx, names)
+			return failures[i]
+		})
+	}
+
+	err := utils.ParallelRequest(ctx, timeout, lookups...)
+	if err != nil && m.cfg.Policy == PolicyStrict {
 		m.deps.Log.Error("failed to API reqeusts", zap.Error(err))
-		return schema.PersonInfo{}, err
+		return nil, errs.E(upstreamKind(err), err)
+	}
+
+	ret := make([]schema.PersonInfo, 0, len(reqs))
+	for _, req := range reqs {
+		key := strings.ToLower(req.Name)
+		res := make([]answer, len(m.deps.Providers))
+		for i := range m.deps.Providers {
+			if failures[i] != nil {
+				res[i].err = failures[i]
+				continue
+			}
+			if j, ok := indexes[i][key]; ok {
+				res[i].Answer = answers[i][j]
+			}
+		}
+		ret = append(ret, m.person(req, res))
 	}
+	return ret, nil
+}
 
+// distinct returns the names of reqs without case-insensitive duplicates,
+// along with the position of each lower-cased name.
+func distinct(reqs []schema.PutRequest) ([]string, map[string]int) {
+	names := make([]string, 0, len(reqs))
+	index := map[string]int{}
+	for _, req := range reqs {
+		key := strings.ToLower(req.Name)
+		if _, ok := index[key]; !ok {
+			index[key] = len(names)
+			names = append(names, req.Name)
+		}
+	}
+	return names, index
+}
+
+// person fills in req with the answers of the providers, listing the failed
+// lookups as pending.
+func (m *Manager) person(req schema.PutRequest, answers []answer) schema.PersonInfo {
 	ret := schema.PersonInfo{
-		Name:    req.Name,
-		Surname: req.Surname,
-		Age:     age,
-		Gender:  gender,
-		Country: nationalize,
+		Name:       req.Name,
+		Surname:    req.Surname,
+		Country:    req.Country,
+		Enrichment: map[string]schema.Enrichment{},
 	}
 
-	return ret, nil
+	for i, p := range m.deps.Providers {
+		attr := p.Attribute()
+		if given(req, attr) {
+			// Marked so that the worker does not replace it with a guess.
+			ret.Enrichment[attr] = schema.Enrichment{Provider: schema.ProviderCaller, FetchedAt: m.now()}
+			continue
+		}
+
+		a := answers[i]
+		if a.err == nil && !ret.SetAttribute(attr, a.Value) {
+			a.err = fmt.Errorf("%s: unexpected %T value for %s", p.Name(), a.Value, attr)
+		}
+		if a.err != nil {
+			m.deps.Log.Warn("storing person without enriched attribute",
+				zap.String("attribute", attr), zap.Error(a.err))
+			ret.Pending = append(ret.Pending, attr)
+			continue
+		}
+		ret.Enrichment[attr] = a.Enrichment
+	}
+
+	return ret
 }
 
-func (m *Manager) AddPersonInfo(ctx context.Context, req schema.PutRequest) error {
+func (m *Manager) AddPersonInfo(ctx context.Context, req schema.PutRequest) (schema.PersonInfo, error) {
 	info, err := m.enrichMessage(ctx, req)
 	if err != nil {
-		return err
+		return schema.PersonInfo{}, err
 	}
 
-	if err = m.deps.DB.AddPersonInfo(ctx, info); err != nil {
+	ret, err := m.deps.DB.AddPersonInfo(ctx, info)
+	if err != nil {
 		m.deps.Log.Error("error adding to database:", zap.Error(err))
-		return err
+		return schema.PersonInfo{}, err
 	}
-	return nil
+	return ret, nil
 }
 
-func (m *Manager) GetPersonInfo(ctx context.Context, req schema.GetRequest) ([]schema.PersonInfo, error) {
+// AddPersonInfoBatch enriches and stores the persons together, returning
+// them in the same order.
+func (m *Manager) AddPersonInfoBatch(ctx context.Context, reqs []schema.PutRequest) ([]schema.PersonInfo, error) {
+	if m.cfg.MaxBatch > 0 && len(reqs) > m.cfg.MaxBatch {
+		return nil, errs.E(errs.KindValidation, validation.Errors{{
+			Field:   "persons",
+			Rule:    "max",
+			Message: fmt.Sprintf("must be at most %d", m.cfg.MaxBatch),
+		}})
+	}
+
+	infos, err := m.enrichBatch(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := m.deps.DB.AddPersonInfoBatch(ctx, infos)
+	if err != nil {
+		m.deps.Log.Error("error adding to database:", zap.Error(err))
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (m *Manager) GetPersonInfo(ctx context.Context, req schema.GetRequest) (schema.GetResponse, error) {
 	ret, err := m.deps.DB.GetPersonInfo(ctx, req)
 	if err != nil {
 		m.deps.Log.Error("error getting from database", zap.Error(err))
-		return nil, err
+		return schema.GetResponse{}, err
 	}
 	return ret, nil
 }
@@ -111,3 +329,20 @@ func (m *Manager) UpdatePersonInfo(ctx context.Context, info schema.PersonInfo)
 	}
 	return nil
 }
+
+func (m *Manager) PatchPersonInfo(ctx context.Context, id int, patch schema.PersonPatch) (schema.PersonInfo, error) {
+	if patch.Empty() {
+		res, err := m.GetPersonInfo(ctx, schema.GetRequest{ID: id})
+		if err != nil {
+			return schema.PersonInfo{}, err
+		}
+		return res.Persons[0], nil
+	}
+
+	ret, err := m.deps.DB.PatchPersonInfo(ctx, id, patch)
+	if err != nil {
+		m.deps.Log.Error("error patching database information", zap.Error(err))
+		return schema.PersonInfo{}, err
+	}
+	return ret, nil
+}
@@ -3,13 +3,19 @@ package manager
 import (
 	"context"
 	"dataservice/internal/api"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/genderapi"
+	"dataservice/internal/api/nationalizeapi"
+	"dataservice/internal/errs"
 	"dataservice/internal/schema"
 	"dataservice/internal/userdb"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
 )
 
 func TestAddPersonInfo(t *testing.T) {
@@ -25,40 +31,65 @@ func TestAddPersonInfo(t *testing.T) {
 	api := api.NewAPIMock(ctrl)
 	db := userdb.NewMockDB(ctrl)
 
-	api.Age.EXPECT().Get(gomock.Any(), name).Return(age, nil)
-	api.Gender.EXPECT().Get(gomock.Any(), name).Return(gender, nil)
-	api.Nationalize.EXPECT().Get(gomock.Any(), name).Return(nationalize, nil)
+	fetchedAt := time.Date(2024, 1, 28, 0, 0, 0, 0, time.UTC)
+	genderConfidence, countryConfidence := 0.99, 0.7
 
-	db.EXPECT().AddPersonInfo(gomock.Any(), schema.PersonInfo{
+	ageRes := ageapi.Result{Age: age, Enrichment: schema.Enrichment{
+		Provider: "agify", Count: 120, FetchedAt: fetchedAt,
+	}}
+	genderRes := genderapi.Result{Gender: gender, Enrichment: schema.Enrichment{
+		Provider: "genderize", Confidence: &genderConfidence, Count: 140, FetchedAt: fetchedAt,
+	}}
+	nationalizeRes := nationalizeapi.Result{Country: nationalize, Enrichment: schema.Enrichment{
+		Provider: "nationalize", Confidence: &countryConfidence, Count: 160, FetchedAt: fetchedAt,
+	}}
+
+	api.Age.EXPECT().Get(gomock.Any(), name).Return(ageRes, nil)
+	api.Gender.EXPECT().Get(gomock.Any(), name).Return(genderRes, nil)
+	api.Nationalize.EXPECT().Get(gomock.Any(), name).Return(nationalizeRes, nil)
+
+	info := schema.PersonInfo{
 		ID:      0,
 		Name:    name,
 		Surname: surname,
 		Age:     age,
 		Gender:  gender,
 		Country: nationalize,
-	}).Return(nil)
+		Enrichment: map[string]schema.Enrichment{
+			schema.AttrAge:     ageRes.Enrichment,
+			schema.AttrGender:  genderRes.Enrichment,
+			schema.AttrCountry: nationalizeRes.Enrichment,
+		},
+	}
+	exp := info
+	exp.ID = 12
+
+	db.EXPECT().AddPersonInfo(gomock.Any(), info).Return(exp, nil)
 
 	mgr := New(Config{Timeout: time.Second}, Dependencies{
-		API: api,
-		DB:  db,
+		Providers: api.Providers(),
+		DB:        db,
 	})
 
-	err := mgr.AddPersonInfo(context.Background(), schema.PutRequest{
+	res, err := mgr.AddPersonInfo(context.Background(), schema.PutRequest{
 		Name:    name,
 		Surname: surname,
 	})
 	require.NoError(t, err)
+	require.Equal(t, exp, res)
 }
 
 func TestGetPersonInfo(t *testing.T) {
-	exp := []schema.PersonInfo{
-		{
-			ID:      12,
-			Name:    "Dmitry",
-			Surname: "Federov",
-			Age:     22,
-			Gender:  "male",
-			Country: "RU",
+	exp := schema.GetResponse{
+		Persons: []schema.PersonInfo{
+			{
+				ID:      12,
+				Name:    "Dmitry",
+				Surname: "Federov",
+				Age:     22,
+				Gender:  "male",
+				Country: "RU",
+			},
 		},
 	}
 
@@ -66,7 +97,7 @@ func TestGetPersonInfo(t *testing.T) {
 	db := userdb.NewMockDB(ctrl)
 
 	db.EXPECT().GetPersonInfo(gomock.Any(), schema.GetRequest{
-		ID: exp[0].ID,
+		ID: exp.Persons[0].ID,
 	}).Return(exp, nil)
 
 	mgr := New(Config{Timeout: time.Second}, Dependencies{
@@ -74,7 +105,7 @@ func TestGetPersonInfo(t *testing.T) {
 	})
 
 	res, err := mgr.GetPersonInfo(context.Background(), schema.GetRequest{
-		ID: exp[0].ID,
+		ID: exp.Persons[0].ID,
 	})
 
 	require.Equal(t, exp, res)
@@ -132,3 +163,291 @@ func TestUpdatePersonInfo(t *testing.T) {
 	})
 	require.NoError(t, err)
 }
+
+func TestPersonInfoNotFound(t *testing.T) {
+	const id = 12
+
+	ctrl := gomock.NewController(t)
+	db := userdb.NewMockDB(ctrl)
+
+	mgr := New(Config{Timeout: time.Second}, Dependencies{
+		DB:  db,
+		Log: zap.NewNop(),
+	})
+
+	db.EXPECT().GetPersonInfo(gomock.Any(), schema.GetRequest{ID: id}).
+		Return(schema.GetResponse{}, userdb.ErrNotFound)
+	_, err := mgr.GetPersonInfo(context.Background(), schema.GetRequest{ID: id})
+	require.ErrorIs(t, err, userdb.ErrNotFound)
+
+	db.EXPECT().DeletePersonInfo(gomock.Any(), id).Return(userdb.ErrNotFound)
+	err = mgr.DeletePersonInfo(context.Background(), id)
+	require.ErrorIs(t, err, userdb.ErrNotFound)
+
+	db.EXPECT().UpdatePersonInfo(gomock.Any(), schema.PersonInfo{ID: id}).
+		Return(userdb.ErrNotFound)
+	err = mgr.UpdatePersonInfo(context.Background(), schema.PersonInfo{ID: id})
+	require.ErrorIs(t, err, userdb.ErrNotFound)
+}
+
+func TestPatchPersonInfo(t *testing.T) {
+	const id = 12
+
+	age := 30
+	exp := schema.PersonInfo{
+		ID:      id,
+		Name:    "Dmitry",
+		Surname: "Federov",
+		Age:     age,
+		Gender:  "male",
+		Country: "RU",
+	}
+
+	ctrl := gomock.NewController(t)
+	db := userdb.NewMockDB(ctrl)
+
+	mgr := New(Config{Timeout: time.Second}, Dependencies{
+		DB:  db,
+		Log: zap.NewNop(),
+	})
+
+	db.EXPECT().PatchPersonInfo(gomock.Any(), id, schema.PersonPatch{Age: &age}).
+		Return(exp, nil)
+	res, err := mgr.PatchPersonInfo(context.Background(), id, schema.PersonPatch{Age: &age})
+	require.NoError(t, err)
+	require.Equal(t, exp, res)
+
+	db.EXPECT().GetPersonInfo(gomock.Any(), schema.GetRequest{ID: id}).
+		Return(schema.GetResponse{Persons: []schema.PersonInfo{exp}}, nil)
+	res, err = mgr.PatchPersonInfo(context.Background(), id, schema.PersonPatch{})
+	require.NoError(t, err)
+	require.Equal(t, exp, res)
+}
+
+func TestAddPersonInfoBestEffort(t *testing.T) {
+	const (
+		name    = "Dmitry"
+		surname = "Federov"
+	)
+
+	ctrl := gomock.NewController(t)
+	api := api.NewAPIMock(ctrl)
+	db := userdb.NewMockDB(ctrl)
+
+	ageRes := ageapi.Result{Age: 22, Enrichment: schema.Enrichment{Provider: "agify"}}
+	genderRes := genderapi.Result{Gender: "male", Enrichment: schema.Enrichment{Provider: "genderize"}}
+
+	api.Age.EXPECT().Get(gomock.Any(), name).Return(ageRes, nil).Times(2)
+	api.Gender.EXPECT().Get(gomock.Any(), name).Return(genderRes, nil).Times(2)
+	api.Nationalize.EXPECT().Get(gomock.Any(), name).
+		Return(nationalizeapi.Result{}, errors.New("nationalize is down")).Times(2)
+
+	info := schema.PersonInfo{
+		Name:    name,
+		Surname: surname,
+		Age:     22,
+		Gender:  "male",
+		Pending: []string{schema.AttrCountry},
+		Enrichment: map[string]schema.Enrichment{
+			schema.AttrAge:    ageRes.Enrichment,
+			schema.AttrGender: genderRes.Enrichment,
+		},
+	}
+	exp := info
+	exp.ID = 12
+
+	db.EXPECT().AddPersonInfo(gomock.Any(), info).Return(exp, nil)
+
+	req := schema.PutRequest{Name: name, Surname: surname}
+	deps := Dependencies{Providers: api.Providers(), DB: db, Log: zap.NewNop()}
+
+	res, err := New(Config{Timeout: time.Second, Policy: PolicyBestEffort}, deps).
+		AddPersonInfo(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, exp, res)
+
+	_, err = New(Config{Timeout: time.Second, Policy: PolicyStrict}, deps).
+		AddPersonInfo(context.Background(), req)
+	require.Equal(t, errs.KindUpstream, errs.KindOf(err))
+}
+
+func TestAddPersonInfoLocalized(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	api := api.NewAPIMock(ctrl)
+	db := userdb.NewMockDB(ctrl)
+
+	nationalize := nationalizeapi.Result{Country: "IS", Enrichment: schema.Enrichment{Provider: "nationalize"}}
+	localAge := ageapi.Result{Age: 60, Enrichment: schema.Enrichment{Provider: "agify", Count: 3, CountryID: "IS"}}
+	globalAge := ageapi.Result{Age: 40, Enrichment: schema.Enrichment{Provider: "agify", Count: 5000}}
+	localGender := genderapi.Result{Gender: "male", Enrichment: schema.Enrichment{
+		Provider: "genderize", Count: 80, CountryID: "IS",
+	}}
+
+	gomock.InOrder(
+		api.Nationalize.EXPECT().Get(gomock.Any(), "Dmitry").Return(nationalize, nil),
+		api.Age.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "IS").Return(localAge, nil),
+		api.Age.EXPECT().Get(gomock.Any(), "Dmitry").Return(globalAge, nil),
+	)
+	api.Gender.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "IS").Return(localGender, nil)
+
+	info := schema.PersonInfo{
+		Name: "Dmitry", Surname: "Federov", Age: 40, Gender: "male", Country: "IS",
+		Enrichment: map[string]schema.Enrichment{
+			schema.AttrAge:     globalAge.Enrichment,
+			schema.AttrGender:  localGender.Enrichment,
+			schema.AttrCountry: nationalize.Enrichment,
+		},
+	}
+	db.EXPECT().AddPersonInfo(gomock.Any(), info).Return(info, nil)
+
+	mgr := New(Config{Timeout: time.Second, Localize: true}, Dependencies{
+		Providers: api.Providers(),
+		DB:        db,
+		Log:       zap.NewNop(),
+	})
+	now := time.Date(2024, 1, 27, 0, 0, 0, 0, time.UTC)
+	mgr.now = func() time.Time { return now }
+
+	res, err := mgr.AddPersonInfo(context.Background(),
+		schema.PutRequest{Name: "Dmitry", Surname: "Federov"})
+	require.NoError(t, err)
+	require.Equal(t, info, res)
+
+	// A country given by the caller is kept, marked as such, and used for
+	// the lookups.
+	localAge.Count = 120
+	api.Age.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "RU").Return(localAge, nil)
+	api.Gender.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "RU").Return(localGender, nil)
+
+	info = schema.PersonInfo{
+		Name: "Dmitry", Surname: "Federov", Age: 60, Gender: "male", Country: "RU",
+		Enrichment: map[string]schema.Enrichment{
+			schema.AttrAge:     localAge.Enrichment,
+			schema.AttrGender:  localGender.Enrichment,
+			schema.AttrCountry: {Provider: schema.ProviderCaller, FetchedAt: now},
+		},
+	}
+	db.EXPECT().AddPersonInfo(gomock.Any(), info).Return(info, nil)
+
+	res, err = mgr.AddPersonInfo(context.Background(),
+		schema.PutRequest{Name: "Dmitry", Surname: "Federov", Country: "RU"})
+	require.NoError(t, err)
+	require.Equal(t, info, res)
+}
+
+func TestAddPersonInfoBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	api := api.NewAPIMock(ctrl)
+	db := userdb.NewMockDB(ctrl)
+
+	agify := schema.Enrichment{Provider: "agify"}
+	genderize := schema.Enrichment{Provider: "genderize"}
+
+	names := []string{"Dmitry", "Anna"}
+	api.Age.EXPECT().GetBatch(gomock.Any(), names).Return([]ageapi.Result{
+		{Age: 22, Enrichment: agify},
+		{Age: 30, Enrichment: agify},
+	}, nil)
+	api.Gender.EXPECT().GetBatch(gomock.Any(), names).Return([]genderapi.Result{
+		{Gender: "male", Enrichment: genderize},
+		{Gender: "female", Enrichment: genderize},
+	}, nil)
+	api.Nationalize.EXPECT().GetBatch(gomock.Any(), names).
+		Return(nil, errors.New("nationalize is down")).Times(2)
+
+	enrichment := map[string]schema.Enrichment{
+		schema.AttrAge:    agify,
+		schema.AttrGender: genderize,
+	}
+	infos := []schema.PersonInfo{
+		{Name: "Dmitry", Surname: "Federov", Age: 22, Gender: "male",
+			Pending: []string{schema.AttrCountry}, Enrichment: enrichment},
+		{Name: "Anna", Surname: "Ivanova", Age: 30, Gender: "female",
+			Pending: []string{schema.AttrCountry}, Enrichment: enrichment},
+		{Name: "dmitry", Surname: "Petrov", Age: 22, Gender: "male",
+			Pending: []string{schema.AttrCountry}, Enrichment: enrichment},
+	}
+	exp := make([]schema.PersonInfo, len(infos))
+	for i, info := range infos {
+		exp[i] = info
+		exp[i].ID = i + 1
+	}
+
+	db.EXPECT().AddPersonInfoBatch(gomock.Any(), infos).Return(exp, nil)
+
+	reqs := []schema.PutRequest{
+		{Name: "Dmitry", Surname: "Federov"},
+		{Name: "Anna", Surname: "Ivanova"},
+		{Name: "dmitry", Surname: "Petrov"},
+	}
+	deps := Dependencies{Providers: api.Providers(), DB: db, Log: zap.NewNop()}
+
+	res, err := New(Config{Timeout: time.Second, Policy: PolicyBestEffort}, deps).
+		AddPersonInfoBatch(context.Background(), reqs)
+	require.NoError(t, err)
+	require.Equal(t, exp, res)
+
+	api.Age.EXPECT().GetBatch(gomock.Any(), names).Return(nil, nil)
+	api.Gender.EXPECT().GetBatch(gomock.Any(), names).Return(nil, nil)
+	_, err = New(Config{Timeout: time.Second, Policy: PolicyStrict}, deps).
+		AddPersonInfoBatch(context.Background(), reqs)
+	require.Equal(t, errs.KindUpstream, errs.KindOf(err))
+
+	_, err = New(Config{Timeout: time.Second, MaxBatch: 2}, deps).
+		AddPersonInfoBatch(context.Background(), reqs)
+	require.Equal(t, errs.KindValidation, errs.KindOf(err))
+	require.ErrorContains(t, err, "persons must be at most 2")
+}
+
+func TestAddPersonInfoProviders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := api.NewAPIMock(ctrl)
+	db := userdb.NewMockDB(ctrl)
+
+	table := api.NewMockProvider(ctrl)
+	table.EXPECT().Name().Return("table").AnyTimes()
+	table.EXPECT().Attribute().Return(schema.AttrCountry).AnyTimes()
+
+	agify := schema.Enrichment{Provider: "agify"}
+	lookup := schema.Enrichment{Provider: "table"}
+
+	mock.Age.EXPECT().Get(gomock.Any(), "Dmitry").Return(ageapi.Result{Age: 22, Enrichment: agify}, nil)
+	table.EXPECT().Lookup(gomock.Any(), api.Query{Name: "Dmitry", MinSamples: api.DefaultMinSamples}).
+		Return(api.Answer{Value: "RU", Enrichment: lookup}, nil)
+
+	info := schema.PersonInfo{
+		Name: "Dmitry", Surname: "Federov", Age: 22, Country: "RU",
+		Enrichment: map[string]schema.Enrichment{
+			schema.AttrAge:     agify,
+			schema.AttrCountry: lookup,
+		},
+	}
+	db.EXPECT().AddPersonInfo(gomock.Any(), info).Return(info, nil)
+
+	// Gender is left out and the country comes from another provider.
+	mgr := New(Config{Timeout: time.Second}, Dependencies{
+		Providers: []api.Provider{mock.Providers()[0], table},
+		DB:        db,
+		Log:       zap.NewNop(),
+	})
+
+	res, err := mgr.AddPersonInfo(context.Background(),
+		schema.PutRequest{Name: "Dmitry", Surname: "Federov"})
+	require.NoError(t, err)
+	require.Equal(t, info, res)
+}
+
+func TestParsePolicy(t *testing.T) {
+	for s, exp := range map[string]Policy{
+		"":            PolicyStrict,
+		"strict":      PolicyStrict,
+		"best-effort": PolicyBestEffort,
+	} {
+		policy, err := ParsePolicy(s)
+		require.NoError(t, err)
+		require.Equal(t, exp, policy)
+	}
+
+	_, err := ParsePolicy("lenient")
+	require.Error(t, err)
+}
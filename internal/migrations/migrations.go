@@ -0,0 +1,253 @@
+package migrations
+
+import (
+	"context"
+	"dataservice/internal/pgxprovider"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockID serializes migrations run concurrently by several instances.
+const lockID = 20240117131515
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version     BIGINT PRIMARY KEY,
+	name        TEXT NOT NULL,
+	applied_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+type Dependencies struct {
+	Log *zap.Logger
+	PGX *pgxprovider.PGXProvider
+}
+
+type Migrator struct {
+	deps       Dependencies
+	migrations []Migration
+}
+
+func New(deps Dependencies) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		deps:       deps,
+		migrations: migrations,
+	}, nil
+}
+
+// load reads <version>_<name>.up.sql and <version>_<name>.down.sql pairs.
+func load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, name := range names {
+		base := path.Base(name)
+		stem, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("malformed migration file name: %s", base)
+		}
+
+		ver, title, ok := strings.Cut(stem, "_")
+		if !ok {
+			return nil, fmt.Errorf("malformed migration file name: %s", base)
+		}
+		version, err := strconv.ParseInt(ver, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed migration version: %s", base)
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	ret := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		ret = append(ret, *m)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Version < ret[j].Version
+	})
+	return ret, nil
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int64]time.Time, error) {
+	rows, err := q.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	ret := map[int64]time.Time{}
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		ret[version] = appliedAt
+	}
+	return ret, rows.Err()
+}
+
+// migrate runs fn in a transaction holding the migration lock, passing the
+// currently applied versions.
+func (m *Migrator) migrate(ctx context.Context, fn func(pgx.Tx, map[int64]time.Time) error) error {
+	if _, err := m.deps.PGX.Exec(ctx, createTable); err != nil {
+		return err
+	}
+
+	tx, err := m.deps.PGX.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, lockID); err != nil {
+		return err
+	}
+
+	applied, err := m.applied(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx, applied); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.migrate(ctx, func(tx pgx.Tx, applied map[int64]time.Time) error {
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			m.deps.Log.Info("applying migration",
+				zap.Int64("version", mig.Version), zap.String("name", mig.Name))
+			if _, err := tx.Exec(ctx, mig.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+				mig.Version, mig.Name); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Down reverts the n most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	count := 0
+	err := m.migrate(ctx, func(tx pgx.Tx, applied map[int64]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0 && count < n; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s cannot be reverted", mig.Version, mig.Name)
+			}
+
+			m.deps.Log.Info("reverting migration",
+				zap.Int64("version", mig.Version), zap.String("name", mig.Name))
+			if _, err := tx.Exec(ctx, mig.Down); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`,
+				mig.Version); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if _, err := m.deps.PGX.Exec(ctx, createTable); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx, m.deps.PGX)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			st.AppliedAt = &at
+		}
+		ret = append(ret, st)
+	}
+	return ret, nil
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/20240201000000_second.up.sql":   {Data: []byte("ALTER TABLE t ADD c INT;")},
+		"sql/20240117131515_first.up.sql":    {Data: []byte("CREATE TABLE t ();")},
+		"sql/20240117131515_first.down.sql":  {Data: []byte("DROP TABLE t;")},
+		"sql/20240201000000_second.down.sql": {Data: []byte("ALTER TABLE t DROP c;")},
+	}
+
+	res, err := load(fsys)
+	require.NoError(t, err)
+	require.Equal(t, []Migration{
+		{
+			Version: 20240117131515,
+			Name:    "first",
+			Up:      "CREATE TABLE t ();",
+			Down:    "DROP TABLE t;",
+		},
+		{
+			Version: 20240201000000,
+			Name:    "second",
+			Up:      "ALTER TABLE t ADD c INT;",
+			Down:    "ALTER TABLE t DROP c;",
+		},
+	}, res)
+
+	_, err = load(fstest.MapFS{"sql/first.up.sql": {}})
+	require.Error(t, err)
+
+	_, err = load(fstest.MapFS{"sql/20240117131515_first.down.sql": {}})
+	require.Error(t, err)
+}
+
+func TestEmbedded(t *testing.T) {
+	res, err := load(files)
+	require.NoError(t, err)
+	require.NotEmpty(t, res)
+
+	for _, m := range res {
+		require.NotEmpty(t, m.Down, "migration %d_%s", m.Version, m.Name)
+	}
+}
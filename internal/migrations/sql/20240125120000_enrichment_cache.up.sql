@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS enrichment_cache (
+    provider    TEXT,
+    name        TEXT,
+    value       JSONB NOT NULL,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (provider, name)
+);
@@ -0,0 +1 @@
+ALTER TABLE userDB DROP COLUMN IF EXISTS pending;
@@ -0,0 +1 @@
+ALTER TABLE userDB ADD COLUMN IF NOT EXISTS pending TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS userdb_enriched_at_idx;
+ALTER TABLE userDB DROP COLUMN IF EXISTS enriched_at;
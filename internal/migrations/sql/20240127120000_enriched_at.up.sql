@@ -0,0 +1,2 @@
+ALTER TABLE userDB ADD COLUMN IF NOT EXISTS enriched_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS userdb_enriched_at_idx ON userDB (enriched_at NULLS FIRST, user_id);
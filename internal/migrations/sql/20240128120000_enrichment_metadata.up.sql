@@ -0,0 +1 @@
+ALTER TABLE userDB ADD COLUMN IF NOT EXISTS enrichment JSONB NOT NULL DEFAULT '{}';
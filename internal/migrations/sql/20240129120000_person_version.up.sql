@@ -0,0 +1 @@
+ALTER TABLE userDB ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0;
@@ -4,20 +4,41 @@ import (
 	"context"
 	"time"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const (
 	defaultConnectTimeout = 2 * time.Second
 )
 
+// Config tunes the connection pool. Zero values keep the pgxpool defaults.
 type Config struct {
-	URL            string
-	ConnectTimeout time.Duration
+	URL               string
+	ConnectTimeout    time.Duration
+	MinConns          int32
+	MaxConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
 }
 
 type PGXProvider struct {
-	*pgx.Conn
+	*pgxpool.Pool
+}
+
+type Stats struct {
+	TotalConns              int32         `json:"total_conns"`
+	IdleConns               int32         `json:"idle_conns"`
+	AcquiredConns           int32         `json:"acquired_conns"`
+	ConstructingConns       int32         `json:"constructing_conns"`
+	MaxConns                int32         `json:"max_conns"`
+	AcquireCount            int64         `json:"acquire_count"`
+	EmptyAcquireCount       int64         `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64         `json:"canceled_acquire_count"`
+	AcquireDuration         time.Duration `json:"acquire_duration"`
+	NewConnsCount           int64         `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64         `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64         `json:"max_idle_destroy_count"`
 }
 
 func New(cfg Config) (*PGXProvider, error) {
@@ -26,19 +47,60 @@ func New(cfg Config) (*PGXProvider, error) {
 		connectTimeout = cfg.ConnectTimeout
 	}
 
+	poolCfg, err := pgxpool.ParseConfig(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	poolCfg.ConnConfig.ConnectTimeout = connectTimeout
+	if cfg.MinConns != 0 {
+		poolCfg.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConns != 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+	if cfg.MaxConnLifetime != 0 {
+		poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime != 0 {
+		poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+	if cfg.HealthCheckPeriod != 0 {
+		poolCfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
 	defer cancel()
 
-	conn, err := pgx.Connect(ctx, cfg.URL)
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
 	return &PGXProvider{
-		Conn: conn,
+		Pool: pool,
 	}, nil
 }
 
-func (pgx *PGXProvider) Close(ctx context.Context) error {
-	return pgx.Conn.Close(context.Background())
+func (pgx *PGXProvider) Stats() Stats {
+	s := pgx.Pool.Stat()
+	return Stats{
+		TotalConns:              s.TotalConns(),
+		IdleConns:               s.IdleConns(),
+		AcquiredConns:           s.AcquiredConns(),
+		ConstructingConns:       s.ConstructingConns(),
+		MaxConns:                s.MaxConns(),
+		AcquireCount:            s.AcquireCount(),
+		EmptyAcquireCount:       s.EmptyAcquireCount(),
+		CanceledAcquireCount:    s.CanceledAcquireCount(),
+		AcquireDuration:         s.AcquireDuration(),
+		NewConnsCount:           s.NewConnsCount(),
+		MaxLifetimeDestroyCount: s.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     s.MaxIdleDestroyCount(),
+	}
 }
@@ -1,30 +1,239 @@
 package schema
 
+import (
+	"slices"
+	"time"
+)
+
 type PutRequest struct {
-	Name    string `json:"name"`
-	Surname string `json:"surname"`
+	Name    string `json:"name" validate:"required,max=30,alphaunicode"`
+	Surname string `json:"surname" validate:"required,max=30,alphaunicode"`
+	// Country, when given, is stored instead of looking up the nationality.
+	Country string `json:"country,omitempty" validate:"omitempty,iso3166_1_alpha2"`
+}
+
+// PutBatchRequest adds several persons at once.
+type PutBatchRequest struct {
+	Persons []PutRequest `json:"persons" validate:"required,min=1,max=1000,dive"`
+}
+
+type PutBatchResponse struct {
+	Persons []PersonInfo `json:"persons"`
 }
 
 type GetRequest struct {
-	ID      int
-	Name    string
-	Surname string
-	Age     int
-	Gender  string
-	Country string
-	Count   int
-	Offset  int
+	ID              int
+	Name            string
+	NamePrefix      string
+	NameContains    string
+	Surname         string
+	SurnamePrefix   string
+	SurnameContains string
+	Age             int
+	AgeMin          int
+	AgeMax          int
+	Genders         []string
+	Countries       []string
+	Sort            []SortField
+	Count           int
+	Offset          int
+	Cursor          string
+	Total           bool
+	// MinConfidence keeps the persons whose every attribute has at least
+	// this confidence, ignoring attributes without one.
+	MinConfidence float64
+}
+
+// SortableFields lists the PersonInfo fields the list can be ordered by.
+var SortableFields = []string{"id", "name", "surname", "age", "gender", "country"}
+
+type SortField struct {
+	Field string
+	Desc  bool
 }
 
 type GetResponse struct {
-	Persons []PersonInfo
+	Persons    []PersonInfo `json:"persons"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Total      *int         `json:"total,omitempty"`
 }
 
+// Attributes filled in by the enrichment providers.
+const (
+	AttrAge     = "age"
+	AttrGender  = "gender"
+	AttrCountry = "country"
+
+	// UnknownCountry is stored when nationalize knows nothing about a name.
+	UnknownCountry = "unknown"
+
+	// ProviderCaller is the provider of the attributes set by the API
+	// caller, which are never looked up again.
+	ProviderCaller = "caller"
+)
+
 type PersonInfo struct {
 	ID      int    `json:"id"`
-	Name    string `json:"name"`
-	Surname string `json:"surname"`
-	Age     int    `json:"age"`
-	Country string `json:"country"`
-	Gender  string `json:"gender"`
+	Name    string `json:"name" validate:"required,max=30,alphaunicode"`
+	Surname string `json:"surname" validate:"required,max=30,alphaunicode"`
+	Age     int    `json:"age" validate:"min=0,max=150"`
+	Country string `json:"country" validate:"omitempty,country"`
+	Gender  string `json:"gender" validate:"omitempty,oneof=male female"`
+	// Pending lists the attributes whose enrichment failed and which are
+	// left empty until filled in.
+	Pending []string `json:"pending,omitempty"`
+	// EnrichedAt is when the attributes were last looked up.
+	EnrichedAt *time.Time `json:"enriched_at,omitempty"`
+	// Enrichment describes the provider answers the attributes come from,
+	// keyed by attribute. Attributes set by hand have a ProviderCaller
+	// entry.
+	Enrichment map[string]Enrichment `json:"enrichment,omitempty"`
+	// Version is bumped on every write, so that re-enrichment does not
+	// overwrite concurrent changes.
+	Version int `json:"-"`
+}
+
+// SetByCaller reports whether attr was set by the API caller.
+func (p PersonInfo) SetByCaller(attr string) bool {
+	return p.Enrichment[attr].Provider == ProviderCaller
+}
+
+// Enrichment describes a provider's answer for an attribute.
+type Enrichment struct {
+	Provider string `json:"provider"`
+	// Confidence is the probability the provider gives the value, nil when
+	// it does not report one.
+	Confidence *float64  `json:"confidence,omitempty"`
+	Count      int       `json:"count"`
+	FetchedAt  time.Time `json:"fetched_at"`
+	// CountryID is the country the answer is localized to, empty for a
+	// global one.
+	CountryID string `json:"country_id,omitempty"`
+	// Candidates are the most likely values, best first, for providers
+	// that rank several.
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+type Candidate struct {
+	Value       string  `json:"value"`
+	Probability float64 `json:"probability"`
+}
+
+// Attribute is an attribute filled in by the enrichment providers.
+type Attribute struct {
+	Name string
+	// Column is the userDB column storing the attribute.
+	Column string
+	// Empty are the values of an unknown attribute, the first one being
+	// stored for it.
+	Empty []any
+	// field points to the attribute in p, as an *int or a *string.
+	field func(p *PersonInfo) any
+}
+
+// Attributes lists the attributes the providers may fill, in the order
+// they are stored and reported.
+var Attributes = []Attribute{
+	{Name: AttrAge, Column: "age", Empty: []any{0},
+		field: func(p *PersonInfo) any { return &p.Age }},
+	{Name: AttrGender, Column: "gender", Empty: []any{""},
+		field: func(p *PersonInfo) any { return &p.Gender }},
+	{Name: AttrCountry, Column: "country", Empty: []any{"", UnknownCountry},
+		field: func(p *PersonInfo) any { return &p.Country }},
+}
+
+// LookupAttribute returns the attribute named name.
+func LookupAttribute(name string) (Attribute, bool) {
+	i := slices.IndexFunc(Attributes, func(a Attribute) bool {
+		return a.Name == name
+	})
+	if i < 0 {
+		return Attribute{}, false
+	}
+	return Attributes[i], true
+}
+
+// Value returns the attribute's value in p.
+func (a Attribute) Value(p PersonInfo) any {
+	switch f := a.field(&p).(type) {
+	case *int:
+		return *f
+	case *string:
+		return *f
+	}
+	return nil
+}
+
+// SetAttribute stores value in the field of attr, reporting whether value
+// has the field's type.
+func (p *PersonInfo) SetAttribute(attr string, value any) bool {
+	a, ok := LookupAttribute(attr)
+	if !ok {
+		return false
+	}
+
+	switch f := a.field(p).(type) {
+	case *int:
+		v, ok := value.(int)
+		if ok {
+			*f = v
+		}
+		return ok
+	case *string:
+		v, ok := value.(string)
+		if ok {
+			*f = v
+		}
+		return ok
+	}
+	return false
+}
+
+// Missing lists the enriched attributes the providers returned nothing for.
+func (p PersonInfo) Missing() []string {
+	ret := make([]string, 0, len(Attributes))
+	for _, a := range Attributes {
+		if slices.Contains(a.Empty, a.Value(p)) {
+			ret = append(ret, a.Name)
+		}
+	}
+	return ret
+}
+
+// StaleRequest selects the persons due for re-enrichment: those with pending
+// attributes, enriched before StaleBefore, or with missing attributes and
+// enriched before RetryBefore.
+type StaleRequest struct {
+	StaleBefore time.Time
+	RetryBefore time.Time
+	Count       int
+}
+
+// EnrichmentUpdate is the outcome of re-enriching a person read at Version.
+type EnrichmentUpdate struct {
+	ID      int
+	Version int
+	// Values and Enrichment hold the attributes looked up successfully.
+	Values     map[string]any
+	Enrichment map[string]Enrichment
+	// Failed lists the attributes whose lookup failed, left pending.
+	Failed []string
+}
+
+// PersonPatch is a JSON Merge Patch of PersonInfo: nil fields are left
+// unchanged.
+type PersonPatch struct {
+	Name    *string `json:"name" validate:"omitempty,max=30,alphaunicode"`
+	Surname *string `json:"surname" validate:"omitempty,max=30,alphaunicode"`
+	Age     *int    `json:"age" validate:"omitempty,min=0,max=150"`
+	Gender  *string `json:"gender" validate:"omitempty,oneof=male female"`
+	Country *string `json:"country" validate:"omitempty,iso3166_1_alpha2"`
+	// Clear lists the attributes set to null, which are emptied and looked
+	// up again.
+	Clear []string `json:"-"`
+}
+
+func (p PersonPatch) Empty() bool {
+	return p.Name == nil && p.Surname == nil && p.Age == nil &&
+		p.Gender == nil && p.Country == nil && len(p.Clear) == 0
 }
@@ -0,0 +1,24 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributes(t *testing.T) {
+	p := PersonInfo{Country: UnknownCountry}
+	require.Equal(t, []string{AttrAge, AttrGender, AttrCountry}, p.Missing())
+
+	require.True(t, p.SetAttribute(AttrAge, 22))
+	require.True(t, p.SetAttribute(AttrCountry, "RU"))
+	require.False(t, p.SetAttribute(AttrGender, 1))
+	require.False(t, p.SetAttribute("email", "dmitry@example.com"))
+	require.Equal(t, PersonInfo{Age: 22, Country: "RU"}, p)
+	require.Equal(t, []string{AttrGender}, p.Missing())
+
+	a, ok := LookupAttribute(AttrCountry)
+	require.True(t, ok)
+	require.Equal(t, "country", a.Column)
+	require.Equal(t, "RU", a.Value(p))
+}
@@ -2,14 +2,24 @@ package server
 
 import (
 	"context"
+	"dataservice/internal/api/breaker"
+	"dataservice/internal/api/cache"
+	"dataservice/internal/api/quota"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/errs"
 	"dataservice/internal/manager"
+	"dataservice/internal/pgxprovider"
 	"dataservice/internal/schema"
+	"dataservice/internal/validation"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,6 +29,7 @@ import (
 
 const (
 	shutdownTimeout = 5 * time.Second
+	statusTimeout   = time.Second
 )
 
 type Config struct {
@@ -26,8 +37,12 @@ type Config struct {
 }
 
 type Dependencies struct {
-	Manager manager.Manager
-	Log     *zap.Logger
+	Manager  manager.Manager
+	PGX      *pgxprovider.PGXProvider
+	Caches   []*cache.Cache
+	Breakers []*breaker.Breaker
+	Quotas   []*quota.Transport
+	Log      *zap.Logger
 }
 
 type Server struct {
@@ -42,18 +57,25 @@ func New(cfg Config, deps Dependencies) *Server {
 	}
 }
 
-func (s *Server) Run(ctx context.Context) error {
+func (s *Server) router() *gin.Engine {
 	router := gin.New()
 
 	router.Use(LoggerMiddleware(s.deps.Log))
 	router.PUT("/", s.addHandler)
+	router.PUT("/batch", s.addBatchHandler)
 	router.GET("/", s.getHandler)
 	router.DELETE("/:id", s.deleteHandler)
 	router.POST("/:id", s.updateHandler)
+	router.PATCH("/:id", s.patchHandler)
+	router.GET("/status", s.statusHandler)
+
+	return router
+}
 
+func (s *Server) Run(ctx context.Context) error {
 	srv := &http.Server{
 		Addr:    s.cfg.Address,
-		Handler: router,
+		Handler: s.router(),
 	}
 
 	serverClosed := make(chan struct{})
@@ -90,18 +112,84 @@ func (s *Server) addHandler(c *gin.Context) {
 	}
 
 	err = json.Unmarshal(data, &req)
-	if s.replyError(c, err) {
+	if s.replyError(c, errs.E(errs.KindValidation, err)) {
 		s.deps.Log.Error("failed to unmarshal request:", zap.Error(err))
 		return
 	}
 
-	err = s.deps.Manager.AddPersonInfo(c, req)
+	if s.replyError(c, validation.Struct(req)) {
+		return
+	}
+
+	res, err := s.deps.Manager.AddPersonInfo(c, req)
 	if s.replyError(c, err) {
 		s.deps.Log.Error("failed add person:", zap.Error(err))
 		return
 	}
 
-	c.Status(http.StatusOK)
+	c.Header("Location", fmt.Sprintf("/%d", res.ID))
+	c.JSON(http.StatusCreated, res)
+}
+
+func (s *Server) addBatchHandler(c *gin.Context) {
+	req := schema.PutBatchRequest{}
+	data, err := io.ReadAll(c.Request.Body)
+	if s.replyError(c, err) {
+		s.deps.Log.Error("failed to read body:", zap.Error(err))
+		return
+	}
+
+	err = json.Unmarshal(data, &req)
+	if s.replyError(c, errs.E(errs.KindValidation, err)) {
+		s.deps.Log.Error("failed to unmarshal request:", zap.Error(err))
+		return
+	}
+
+	if s.replyError(c, validation.Struct(req)) {
+		return
+	}
+
+	res, err := s.deps.Manager.AddPersonInfoBatch(c, req.Persons)
+	if s.replyError(c, err) {
+		s.deps.Log.Error("failed add persons:", zap.Error(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, schema.PutBatchResponse{Persons: res})
+}
+
+func splitList(values []string) []string {
+	ret := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				ret = append(ret, v)
+			}
+		}
+	}
+	return ret
+}
+
+func parseSort(value string) ([]schema.SortField, error) {
+	ret := make([]schema.SortField, 0)
+	seen := map[string]bool{}
+	for _, v := range splitList([]string{value}) {
+		f := schema.SortField{Field: v}
+		if strings.HasPrefix(v, "-") {
+			f = schema.SortField{Field: v[1:], Desc: true}
+		}
+
+		if !slices.Contains(schema.SortableFields, f.Field) {
+			return nil, fmt.Errorf("unknown sort field %q", f.Field)
+		}
+		if seen[f.Field] {
+			return nil, fmt.Errorf("duplicate sort field %q", f.Field)
+		}
+		seen[f.Field] = true
+
+		ret = append(ret, f)
+	}
+	return ret, nil
 }
 
 func (s *Server) getQuery(query url.Values) (schema.GetRequest, error) {
@@ -115,29 +203,64 @@ func (s *Server) getQuery(query url.Values) (schema.GetRequest, error) {
 			ret.ID, err = strconv.Atoi(v)
 		case "name":
 			ret.Name = v
+		case "name_prefix":
+			ret.NamePrefix = v
+		case "name_contains":
+			ret.NameContains = v
 		case "surname":
 			ret.Surname = v
+		case "surname_prefix":
+			ret.SurnamePrefix = v
+		case "surname_contains":
+			ret.SurnameContains = v
 		case "age":
 			ret.Age, err = strconv.Atoi(v)
+		case "age_min":
+			ret.AgeMin, err = strconv.Atoi(v)
+		case "age_max":
+			ret.AgeMax, err = strconv.Atoi(v)
 		case "gender":
-			ret.Gender = v
+			ret.Genders = splitList(value)
 		case "country":
-			ret.Country = v
+			ret.Countries = splitList(value)
+		case "sort":
+			ret.Sort, err = parseSort(v)
 		case "count":
 			ret.Count, err = strconv.Atoi(v)
 		case "offset":
 			ret.Offset, err = strconv.Atoi(v)
+		case "cursor":
+			ret.Cursor = v
+		case "total":
+			ret.Total, err = strconv.ParseBool(v)
+		case "min_confidence":
+			ret.MinConfidence, err = strconv.ParseFloat(v, 64)
 		default:
 		}
 
 		if err != nil {
 			s.deps.Log.Error("failed to read query",
 				zap.String("field", key), zap.String("value", v))
-			return schema.GetRequest{},
-				errors.WithMessagef(err, "failed to read query: %s=%s", key, v)
+			return schema.GetRequest{}, errs.E(errs.KindValidation,
+				errors.WithMessagef(err, "failed to read query: %s=%s", key, v))
 		}
 	}
 
+	if ret.Cursor != "" && ret.Offset != 0 {
+		return schema.GetRequest{}, errs.New(errs.KindValidation,
+			"failed to read query: cursor and offset are mutually exclusive")
+	}
+
+	if !(ret.MinConfidence >= 0 && ret.MinConfidence <= 1) {
+		return schema.GetRequest{}, errs.New(errs.KindValidation,
+			"failed to read query: min_confidence must be between 0 and 1")
+	}
+
+	if ret.AgeMin != 0 && ret.AgeMax != 0 && ret.AgeMin > ret.AgeMax {
+		return schema.GetRequest{}, errs.New(errs.KindValidation,
+			"failed to read query: age_min is greater than age_max")
+	}
+
 	return ret, nil
 }
 
@@ -157,7 +280,7 @@ func (s *Server) getHandler(c *gin.Context) {
 
 func (s *Server) deleteHandler(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
-	if s.replyError(c, err) {
+	if s.replyError(c, errs.E(errs.KindValidation, err)) {
 		s.deps.Log.Error("incorrect ID:", zap.Error(err))
 		return
 	}
@@ -173,7 +296,7 @@ func (s *Server) deleteHandler(c *gin.Context) {
 func (s *Server) updateHandler(c *gin.Context) {
 	value := c.Param("id")
 	id, err := strconv.Atoi(value)
-	if s.replyError(c, err) {
+	if s.replyError(c, errs.E(errs.KindValidation, err)) {
 		s.deps.Log.Error("incorrect ID:", zap.Error(err))
 		return
 	}
@@ -186,11 +309,15 @@ func (s *Server) updateHandler(c *gin.Context) {
 	}
 
 	err = json.Unmarshal(data, &info)
-	if s.replyError(c, err) {
+	if s.replyError(c, errs.E(errs.KindValidation, err)) {
 		s.deps.Log.Error("failed to unmarshal request:", zap.Error(err))
 		return
 	}
 
+	if s.replyError(c, validation.Struct(info)) {
+		return
+	}
+
 	info.ID = id
 	err = s.deps.Manager.UpdatePersonInfo(c, info)
 	if s.replyError(c, err) {
@@ -200,8 +327,135 @@ func (s *Server) updateHandler(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+func (s *Server) readPatch(data []byte) (schema.PersonPatch, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return schema.PersonPatch{}, err
+	}
+
+	patch := schema.PersonPatch{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return schema.PersonPatch{}, err
+	}
+
+	// null removes an attribute, as in RFC 7396, while name and surname
+	// cannot be removed.
+	var removed validation.Errors
+	for key, value := range fields {
+		if string(value) != "null" {
+			continue
+		}
+		if _, ok := schema.LookupAttribute(key); ok {
+			patch.Clear = append(patch.Clear, key)
+			continue
+		}
+		switch key {
+		case "name", "surname":
+			removed = append(removed, validation.FieldError{
+				Field: key, Rule: "required", Message: "cannot be removed",
+			})
+		}
+	}
+	if len(removed) != 0 {
+		slices.SortFunc(removed, func(a, b validation.FieldError) int {
+			return strings.Compare(a.Field, b.Field)
+		})
+		return schema.PersonPatch{}, errs.E(errs.KindValidation, removed)
+	}
+	slices.Sort(patch.Clear)
+
+	return patch, validation.Struct(patch)
+}
+
+func (s *Server) patchHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if s.replyError(c, errs.E(errs.KindValidation, err)) {
+		s.deps.Log.Error("incorrect ID:", zap.Error(err))
+		return
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if s.replyError(c, err) {
+		s.deps.Log.Error("failed to read body:", zap.Error(err))
+		return
+	}
+
+	patch, err := s.readPatch(data)
+	if s.replyError(c, errs.E(errs.KindValidation, err)) {
+		s.deps.Log.Error("failed to read patch:", zap.Error(err))
+		return
+	}
+
+	res, err := s.deps.Manager.PatchPersonInfo(c, id, patch)
+	if s.replyError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+type databaseStatus struct {
+	Healthy bool              `json:"healthy"`
+	Error   string            `json:"error,omitempty"`
+	Pool    pgxprovider.Stats `json:"pool"`
+}
+
+type statusResponse struct {
+	Database *databaseStatus `json:"database,omitempty"`
+	Caches   []cache.Stats   `json:"caches,omitempty"`
+	Breakers []breaker.Stats `json:"breakers,omitempty"`
+	Quotas   []quota.Stats   `json:"quotas,omitempty"`
+}
+
+func (s *Server) statusHandler(c *gin.Context) {
+	resp := statusResponse{}
+	status := http.StatusOK
+
+	if s.deps.PGX != nil {
+		ctx, cancel := context.WithTimeout(c, statusTimeout)
+		defer cancel()
+
+		db := &databaseStatus{Healthy: true}
+		if err := s.deps.PGX.Ping(ctx); err != nil {
+			s.deps.Log.Error("database ping failed", zap.Error(err))
+			db.Healthy = false
+			db.Error = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+		db.Pool = s.deps.PGX.Stats()
+		resp.Database = db
+	}
+
+	for _, cc := range s.deps.Caches {
+		resp.Caches = append(resp.Caches, cc.Stats())
+	}
+
+	for _, b := range s.deps.Breakers {
+		resp.Breakers = append(resp.Breakers, b.Stats())
+	}
+
+	for _, q := range s.deps.Quotas {
+		resp.Quotas = append(resp.Quotas, q.Stats())
+	}
+
+	c.JSON(status, &resp)
+}
+
+var kindStatuses = map[errs.Kind]int{
+	errs.KindInternal:    http.StatusInternalServerError,
+	errs.KindNotFound:    http.StatusNotFound,
+	errs.KindValidation:  http.StatusUnprocessableEntity,
+	errs.KindUpstream:    http.StatusBadGateway,
+	errs.KindUnavailable: http.StatusServiceUnavailable,
+	errs.KindConflict:    http.StatusConflict,
+	errs.KindTimeout:     http.StatusGatewayTimeout,
+	errs.KindCanceled:    499, // the client is gone, as nginx logs it
+}
+
 type errorResponse struct {
-	Message string
+	Code    string                  `json:"code"`
+	Message string                  `json:"message"`
+	Details []validation.FieldError `json:"details,omitempty"`
 }
 
 func (s *Server) replyError(c *gin.Context, err error) bool {
@@ -209,7 +463,31 @@ func (s *Server) replyError(c *gin.Context, err error) bool {
 		return false
 	}
 
-	resp := errorResponse{Message: err.Error()}
-	c.JSON(http.StatusBadRequest, &resp)
+	kind := errs.KindOf(err)
+	status, ok := kindStatuses[kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	resp := errorResponse{
+		Code:    kind.Code(),
+		Message: err.Error(),
+	}
+
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		resp.Details = fields
+	}
+
+	var (
+		ue *upstream.Error
+		qe *quota.Error
+	)
+	if errors.As(err, &ue) && ue.RateLimited() && ue.RateLimitReset > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(ue.RateLimitReset.Seconds())))
+	} else if errors.As(err, &qe) && qe.Reset > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(qe.Reset.Seconds()))))
+	}
+	c.JSON(status, &resp)
 	return true
 }
@@ -0,0 +1,492 @@
+package server
+
+import (
+	"context"
+	"dataservice/internal/api"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/breaker"
+	"dataservice/internal/api/genderapi"
+	"dataservice/internal/api/nationalizeapi"
+	"dataservice/internal/api/quota"
+	"dataservice/internal/api/upstream"
+	"dataservice/internal/errs"
+	"dataservice/internal/manager"
+	"dataservice/internal/schema"
+	"dataservice/internal/userdb"
+	"dataservice/internal/validation"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func newTestServer(t *testing.T) (*Server, *api.APIMock, *userdb.MockDB) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := gomock.NewController(t)
+	api := api.NewAPIMock(ctrl)
+	db := userdb.NewMockDB(ctrl)
+	log := zap.NewNop()
+
+	mgr := manager.New(manager.Config{Timeout: time.Second}, manager.Dependencies{
+		Providers: api.Providers(),
+		DB:        db,
+		Log:       log,
+	})
+
+	srv := New(Config{}, Dependencies{
+		Manager: *mgr,
+		Log:     log,
+	})
+	return srv, api, db
+}
+
+func doRequest(srv *Server, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	srv.router().ServeHTTP(w, req)
+	return w
+}
+
+func requireError(t *testing.T, w *httptest.ResponseRecorder, status int, kind errs.Kind) {
+	t.Helper()
+
+	require.Equal(t, status, w.Code)
+
+	resp := errorResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, kind.Code(), resp.Code)
+	require.NotEmpty(t, resp.Message)
+}
+
+func TestErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		kind   errs.Kind
+	}{
+		{
+			name:   "not found",
+			err:    userdb.ErrNotFound,
+			status: http.StatusNotFound,
+			kind:   errs.KindNotFound,
+		},
+		{
+			name:   "unavailable",
+			err:    errs.E(errs.KindUnavailable, errors.New("connection refused")),
+			status: http.StatusServiceUnavailable,
+			kind:   errs.KindUnavailable,
+		},
+		{
+			name:   "conflict",
+			err:    errs.E(errs.KindConflict, errors.New("duplicate key")),
+			status: http.StatusConflict,
+			kind:   errs.KindConflict,
+		},
+		{
+			name:   "canceled",
+			err:    errs.E(errs.KindCanceled, context.Canceled),
+			status: 499,
+			kind:   errs.KindCanceled,
+		},
+		{
+			name:   "unclassified",
+			err:    errors.New("boom"),
+			status: http.StatusInternalServerError,
+			kind:   errs.KindInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _, db := newTestServer(t)
+			db.EXPECT().DeletePersonInfo(gomock.Any(), 12).Return(tt.err)
+
+			w := doRequest(srv, http.MethodDelete, "/12", "")
+			requireError(t, w, tt.status, tt.kind)
+		})
+	}
+}
+
+func TestInvalidInput(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	w := doRequest(srv, http.MethodDelete, "/abc", "")
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+
+	w = doRequest(srv, http.MethodGet, "/?age=abc", "")
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+
+	w = doRequest(srv, http.MethodPut, "/", "{")
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+}
+
+func TestUpstreamFailure(t *testing.T) {
+	const name = "Dmitry"
+
+	srv, api, _ := newTestServer(t)
+	api.Age.EXPECT().Get(gomock.Any(), name).Return(ageapi.Result{}, errors.New("agify is down"))
+	api.Gender.EXPECT().Get(gomock.Any(), name).Return(genderapi.Result{Gender: "male"}, nil)
+	api.Nationalize.EXPECT().Get(gomock.Any(), name).Return(nationalizeapi.Result{Country: "RU"}, nil)
+
+	w := doRequest(srv, http.MethodPut, "/", `{"name":"Dmitry","surname":"Federov"}`)
+	requireError(t, w, http.StatusBadGateway, errs.KindUpstream)
+}
+
+func TestUpstreamRateLimited(t *testing.T) {
+	const name = "Dmitry"
+
+	srv, api, _ := newTestServer(t)
+	api.Age.EXPECT().Get(gomock.Any(), name).Return(ageapi.Result{}, &upstream.Error{
+		Provider:       "agify",
+		Status:         http.StatusTooManyRequests,
+		Message:        "Request limit reached",
+		RateLimitReset: time.Minute,
+	})
+	api.Gender.EXPECT().Get(gomock.Any(), name).Return(genderapi.Result{Gender: "male"}, nil)
+	api.Nationalize.EXPECT().Get(gomock.Any(), name).Return(nationalizeapi.Result{Country: "RU"}, nil)
+
+	w := doRequest(srv, http.MethodPut, "/", `{"name":"Dmitry","surname":"Federov"}`)
+	requireError(t, w, http.StatusServiceUnavailable, errs.KindUnavailable)
+	require.Equal(t, "60", w.Header().Get("Retry-After"))
+}
+
+func TestQuotaExhausted(t *testing.T) {
+	const name = "Dmitry"
+
+	srv, api, _ := newTestServer(t)
+	api.Age.EXPECT().Get(gomock.Any(), name).Return(ageapi.Result{}, errs.E(errs.KindUnavailable,
+		&quota.Error{Provider: "agify", Reset: 90 * time.Second}))
+	api.Gender.EXPECT().Get(gomock.Any(), name).Return(genderapi.Result{Gender: "male"}, nil)
+	api.Nationalize.EXPECT().Get(gomock.Any(), name).Return(nationalizeapi.Result{Country: "RU"}, nil)
+
+	w := doRequest(srv, http.MethodPut, "/", `{"name":"Dmitry","surname":"Federov"}`)
+	requireError(t, w, http.StatusServiceUnavailable, errs.KindUnavailable)
+	require.Equal(t, "90", w.Header().Get("Retry-After"))
+	require.Contains(t, w.Body.String(), "agify: quota exhausted")
+}
+
+func TestAddPersonInfoBatch(t *testing.T) {
+	srv, api, db := newTestServer(t)
+
+	names := []string{"Dmitry", "Anna"}
+	api.Age.EXPECT().GetBatch(gomock.Any(), names).
+		Return([]ageapi.Result{{Age: 22}, {Age: 30}}, nil)
+	api.Gender.EXPECT().GetBatch(gomock.Any(), names).
+		Return([]genderapi.Result{{Gender: "male"}, {Gender: "female"}}, nil)
+	api.Nationalize.EXPECT().GetBatch(gomock.Any(), names).
+		Return([]nationalizeapi.Result{{Country: "RU"}, {Country: "UA"}}, nil)
+
+	exp := []schema.PersonInfo{
+		{ID: 1, Name: "Dmitry", Surname: "Federov", Age: 22, Gender: "male", Country: "RU"},
+		{ID: 2, Name: "Anna", Surname: "Ivanova", Age: 30, Gender: "female", Country: "UA"},
+	}
+	db.EXPECT().AddPersonInfoBatch(gomock.Any(), gomock.Len(2)).Return(exp, nil)
+
+	w := doRequest(srv, http.MethodPut, "/batch", `{"persons":[
+		{"name":"Dmitry","surname":"Federov"},
+		{"name":"Anna","surname":"Ivanova"}
+	]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	resp := schema.PutBatchResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, exp, resp.Persons)
+}
+
+func TestAddPersonInfoBatchInvalid(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	w := doRequest(srv, http.MethodPut, "/batch", `{"persons":[]}`)
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+
+	w = doRequest(srv, http.MethodPut, "/batch", `{"persons":[{"name":"Dmitry"}]}`)
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+
+	resp := errorResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "persons[0].surname", resp.Details[0].Field)
+}
+
+func TestGetPersonInfo(t *testing.T) {
+	total := 42
+	exp := schema.GetResponse{
+		Persons: []schema.PersonInfo{
+			{
+				ID:      12,
+				Name:    "Dmitry",
+				Surname: "Federov",
+				Age:     22,
+				Gender:  "male",
+				Country: "RU",
+			},
+		},
+		NextCursor: "next",
+		Total:      &total,
+	}
+
+	srv, _, db := newTestServer(t)
+	db.EXPECT().GetPersonInfo(gomock.Any(), schema.GetRequest{
+		Count:  1,
+		Cursor: "prev",
+		Total:  true,
+	}).Return(exp, nil)
+
+	w := doRequest(srv, http.MethodGet, "/?count=1&cursor=prev&total=true", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	res := schema.GetResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Equal(t, exp, res)
+
+	w = doRequest(srv, http.MethodGet, "/?cursor=prev&offset=10", "")
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+}
+
+func TestUpdateRoundTrip(t *testing.T) {
+	stored := schema.PersonInfo{
+		ID: 12, Name: "Dmitry", Surname: "Federov", Age: 22, Gender: "male",
+		Country: schema.UnknownCountry,
+	}
+
+	srv, _, db := newTestServer(t)
+	db.EXPECT().GetPersonInfo(gomock.Any(), schema.GetRequest{ID: 12}).
+		Return(schema.GetResponse{Persons: []schema.PersonInfo{stored}}, nil)
+	w := doRequest(srv, http.MethodGet, "/?id=12", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	res := schema.GetResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	body, err := json.Marshal(res.Persons[0])
+	require.NoError(t, err)
+
+	// A person read from the service is accepted back as it is.
+	db.EXPECT().UpdatePersonInfo(gomock.Any(), stored).Return(nil)
+	w = doRequest(srv, http.MethodPost, "/12", string(body))
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestNotFound(t *testing.T) {
+	const id = 12
+
+	srv, _, db := newTestServer(t)
+
+	db.EXPECT().GetPersonInfo(gomock.Any(), schema.GetRequest{ID: id}).
+		Return(schema.GetResponse{}, userdb.ErrNotFound)
+	w := doRequest(srv, http.MethodGet, "/?id=12", "")
+	requireError(t, w, http.StatusNotFound, errs.KindNotFound)
+
+	db.EXPECT().DeletePersonInfo(gomock.Any(), id).Return(userdb.ErrNotFound)
+	w = doRequest(srv, http.MethodDelete, "/12", "")
+	requireError(t, w, http.StatusNotFound, errs.KindNotFound)
+
+	db.EXPECT().UpdatePersonInfo(gomock.Any(), schema.PersonInfo{
+		ID:      id,
+		Name:    "Dmitry",
+		Surname: "Federov",
+	}).Return(userdb.ErrNotFound)
+	w = doRequest(srv, http.MethodPost, "/12", `{"name":"Dmitry","surname":"Federov"}`)
+	requireError(t, w, http.StatusNotFound, errs.KindNotFound)
+}
+
+func TestDeletePersonInfo(t *testing.T) {
+	srv, _, db := newTestServer(t)
+
+	db.EXPECT().DeletePersonInfo(gomock.Any(), 12).Return(nil)
+	w := doRequest(srv, http.MethodDelete, "/12", "")
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPatchPersonInfo(t *testing.T) {
+	const id = 12
+
+	age := 30
+	exp := schema.PersonInfo{
+		ID:      id,
+		Name:    "Dmitry",
+		Surname: "Federov",
+		Age:     age,
+		Gender:  "male",
+		Country: "RU",
+	}
+
+	srv, _, db := newTestServer(t)
+
+	db.EXPECT().PatchPersonInfo(gomock.Any(), id, schema.PersonPatch{Age: &age}).
+		Return(exp, nil)
+	w := doRequest(srv, http.MethodPatch, "/12", `{"age":30}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	res := schema.PersonInfo{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Equal(t, exp, res)
+
+	// null removes an attribute, but not the name.
+	cleared := exp
+	cleared.Gender, cleared.Country = "", ""
+	cleared.Pending = []string{schema.AttrCountry, schema.AttrGender}
+	db.EXPECT().PatchPersonInfo(gomock.Any(), id,
+		schema.PersonPatch{Clear: []string{schema.AttrCountry, schema.AttrGender}}).
+		Return(cleared, nil)
+	w = doRequest(srv, http.MethodPatch, "/12", `{"gender":null,"country":null}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(srv, http.MethodPatch, "/12", `{"name":null,"age":null}`)
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+	resp := errorResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, []validation.FieldError{
+		{Field: "name", Rule: "required", Message: "cannot be removed"},
+	}, resp.Details)
+
+	db.EXPECT().PatchPersonInfo(gomock.Any(), id, schema.PersonPatch{Age: &age}).
+		Return(schema.PersonInfo{}, userdb.ErrNotFound)
+	w = doRequest(srv, http.MethodPatch, "/12", `{"age":30}`)
+	requireError(t, w, http.StatusNotFound, errs.KindNotFound)
+}
+
+func TestAddPersonInfo(t *testing.T) {
+	const name = "Dmitry"
+
+	exp := schema.PersonInfo{
+		ID:      12,
+		Name:    name,
+		Surname: "Federov",
+		Age:     22,
+		Gender:  "male",
+		Country: "RU",
+		Enrichment: map[string]schema.Enrichment{
+			schema.AttrAge:     {Provider: "agify"},
+			schema.AttrGender:  {Provider: "genderize"},
+			schema.AttrCountry: {Provider: "nationalize"},
+		},
+	}
+
+	srv, api, db := newTestServer(t)
+	api.Age.EXPECT().Get(gomock.Any(), name).Return(ageapi.Result{
+		Age: exp.Age, Enrichment: exp.Enrichment[schema.AttrAge],
+	}, nil)
+	api.Gender.EXPECT().Get(gomock.Any(), name).Return(genderapi.Result{
+		Gender: exp.Gender, Enrichment: exp.Enrichment[schema.AttrGender],
+	}, nil)
+	api.Nationalize.EXPECT().Get(gomock.Any(), name).Return(nationalizeapi.Result{
+		Country: exp.Country, Enrichment: exp.Enrichment[schema.AttrCountry],
+	}, nil)
+
+	info := exp
+	info.ID = 0
+	db.EXPECT().AddPersonInfo(gomock.Any(), info).Return(exp, nil)
+
+	w := doRequest(srv, http.MethodPut, "/", `{"name":"Dmitry","surname":"Federov"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "/12", w.Header().Get("Location"))
+
+	res := schema.PersonInfo{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Equal(t, exp, res)
+}
+
+func TestValidation(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	w := doRequest(srv, http.MethodPut, "/", `{"name":"","surname":"Federov1"}`)
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+
+	resp := errorResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, []validation.FieldError{
+		{Field: "name", Rule: "required", Message: "is required"},
+		{Field: "surname", Rule: "alphaunicode", Message: "must contain only letters"},
+	}, resp.Details)
+
+	w = doRequest(srv, http.MethodPost, "/12",
+		`{"name":"Dmitry","surname":"Federov","age":-1,"gender":"robot","country":"XX"}`)
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+
+	w = doRequest(srv, http.MethodPatch, "/12", `{"surname":"Federovvvvvvvvvvvvvvvvvvvvvvvvvv"}`)
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+}
+
+func TestGetFilters(t *testing.T) {
+	srv, _, db := newTestServer(t)
+
+	db.EXPECT().GetPersonInfo(gomock.Any(), schema.GetRequest{
+		NamePrefix: "dm",
+		AgeMin:     18,
+		AgeMax:     30,
+		Genders:    []string{"male"},
+		Countries:  []string{"RU", "UA", "KZ"},
+	}).Return(schema.GetResponse{}, nil)
+
+	w := doRequest(srv, http.MethodGet,
+		"/?country=RU,UA&country=KZ&gender=male&age_min=18&age_max=30&name_prefix=dm", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(srv, http.MethodGet, "/?age_min=30&age_max=18", "")
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+
+	db.EXPECT().GetPersonInfo(gomock.Any(), schema.GetRequest{
+		MinConfidence: 0.75,
+	}).Return(schema.GetResponse{}, nil)
+
+	w = doRequest(srv, http.MethodGet, "/?min_confidence=0.75", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	for _, v := range []string{"1.5", "-0.1", "NaN", "high"} {
+		w = doRequest(srv, http.MethodGet, "/?min_confidence="+v, "")
+		requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+	}
+}
+
+func TestGetSort(t *testing.T) {
+	srv, _, db := newTestServer(t)
+
+	db.EXPECT().GetPersonInfo(gomock.Any(), schema.GetRequest{
+		Sort: []schema.SortField{
+			{Field: "age"},
+			{Field: "surname", Desc: true},
+		},
+	}).Return(schema.GetResponse{}, nil)
+
+	w := doRequest(srv, http.MethodGet, "/?sort=age,-surname", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(srv, http.MethodGet, "/?sort=user_name", "")
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+
+	w = doRequest(srv, http.MethodGet, "/?sort=age,-age", "")
+	requireError(t, w, http.StatusUnprocessableEntity, errs.KindValidation)
+}
+
+func TestStatus(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	w := doRequest(srv, http.MethodGet, "/status", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{}`, w.Body.String())
+}
+
+func TestStatusBreakers(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+	srv.deps.Breakers = []*breaker.Breaker{
+		breaker.New(breaker.Config{Provider: "agify"}, breaker.Dependencies{Log: zap.NewNop()}),
+	}
+
+	w := doRequest(srv, http.MethodGet, "/status", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	resp := statusResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Breakers, 1)
+	require.Equal(t, "agify", resp.Breakers[0].Provider)
+	require.Equal(t, "closed", resp.Breakers[0].State)
+}
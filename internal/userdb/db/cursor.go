@@ -0,0 +1,181 @@
+package db
+
+import (
+	"bytes"
+	"dataservice/internal/errs"
+	"dataservice/internal/schema"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+)
+
+var sortColumns = map[string]string{
+	"id":      "user_id",
+	"name":    "user_name",
+	"surname": "surname",
+	"age":     "age",
+	"gender":  "gender",
+	"country": "country",
+}
+
+type orderColumn struct {
+	field  string
+	column string
+	desc   bool
+}
+
+// orderColumns resolves the requested sort into columns, always ending with
+// user_id so that the order is total.
+func orderColumns(sort []schema.SortField) ([]orderColumn, error) {
+	ret := make([]orderColumn, 0, len(sort)+1)
+	hasID := false
+	for _, f := range sort {
+		column, ok := sortColumns[f.Field]
+		if !ok {
+			return nil, errs.New(errs.KindValidation, "unknown sort field: "+f.Field)
+		}
+		ret = append(ret, orderColumn{field: f.Field, column: column, desc: f.Desc})
+
+		if f.Field == "id" {
+			hasID = true
+			break
+		}
+	}
+
+	if !hasID {
+		ret = append(ret, orderColumn{field: "id", column: sortColumns["id"]})
+	}
+	return ret, nil
+}
+
+func orderBy(columns []orderColumn) []string {
+	ret := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if c.desc {
+			ret = append(ret, c.column+" DESC")
+		} else {
+			ret = append(ret, c.column)
+		}
+	}
+	return ret
+}
+
+func fieldValue(info schema.PersonInfo, field string) any {
+	switch field {
+	case "id":
+		return info.ID
+	case "name":
+		return info.Name
+	case "surname":
+		return info.Surname
+	case "age":
+		return info.Age
+	case "gender":
+		return info.Gender
+	case "country":
+		return info.Country
+	}
+	return nil
+}
+
+func sortSpec(sort []schema.SortField) string {
+	fields := make([]string, 0, len(sort))
+	for _, f := range sort {
+		if f.Desc {
+			fields = append(fields, "-"+f.Field)
+		} else {
+			fields = append(fields, f.Field)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// cursor points right after the row holding Values in the order given by Sort.
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+func encodeCursor(sort []schema.SortField, columns []orderColumn, last schema.PersonInfo) (string, error) {
+	cur := cursor{
+		Sort:   sortSpec(sort),
+		Values: make([]any, 0, len(columns)),
+	}
+	for _, c := range columns {
+		cur.Values = append(cur.Values, fieldValue(last, c.field))
+	}
+
+	data, err := json.Marshal(cur)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+var errInvalidCursor = errs.New(errs.KindValidation, "invalid cursor")
+
+func decodeCursor(value string, sort []schema.SortField, columns []orderColumn) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	cur := cursor{}
+	if err := dec.Decode(&cur); err != nil {
+		return nil, errInvalidCursor
+	}
+	if cur.Sort != sortSpec(sort) {
+		return nil, errs.New(errs.KindValidation, "cursor does not match sort")
+	}
+	if len(cur.Values) != len(columns) {
+		return nil, errInvalidCursor
+	}
+
+	ret := make([]any, 0, len(columns))
+	for i, c := range columns {
+		switch fieldValue(schema.PersonInfo{}, c.field).(type) {
+		case int:
+			num, ok := cur.Values[i].(json.Number)
+			if !ok {
+				return nil, errInvalidCursor
+			}
+			v, err := num.Int64()
+			if err != nil {
+				return nil, errInvalidCursor
+			}
+			ret = append(ret, int(v))
+		case string:
+			v, ok := cur.Values[i].(string)
+			if !ok {
+				return nil, errInvalidCursor
+			}
+			ret = append(ret, v)
+		}
+	}
+	return ret, nil
+}
+
+// keysetPredicate selects the rows that follow values in the given order:
+// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ...
+func keysetPredicate(columns []orderColumn, values []any) squirrel.Sqlizer {
+	ret := squirrel.Or{}
+	for i, c := range columns {
+		pred := squirrel.And{}
+		for j := 0; j < i; j++ {
+			pred = append(pred, squirrel.Eq{columns[j].column: values[j]})
+		}
+
+		if c.desc {
+			pred = append(pred, squirrel.Lt{c.column: values[i]})
+		} else {
+			pred = append(pred, squirrel.Gt{c.column: values[i]})
+		}
+		ret = append(ret, pred)
+	}
+	return ret
+}
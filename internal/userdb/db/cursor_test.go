@@ -0,0 +1,57 @@
+package db
+
+import (
+	"dataservice/internal/errs"
+	"dataservice/internal/schema"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	sort := []schema.SortField{
+		{Field: "age"},
+		{Field: "surname", Desc: true},
+	}
+	last := schema.PersonInfo{ID: 12, Name: "Dmitry", Surname: "Federov", Age: 22}
+
+	columns, err := orderColumns(sort)
+	require.NoError(t, err)
+
+	cur, err := encodeCursor(sort, columns, last)
+	require.NoError(t, err)
+
+	values, err := decodeCursor(cur, sort, columns)
+	require.NoError(t, err)
+	require.Equal(t, []any{22, "Federov", 12}, values)
+
+	p := &Postgres{}
+	sql, args, err := p.buildGetQuery(schema.GetRequest{
+		Sort:   sort,
+		Cursor: cur,
+		Count:  10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "SELECT "+personColumns+" FROM userDB"+
+		" WHERE ((age > $1) OR (age = $2 AND surname < $3) OR (age = $4 AND surname = $5 AND user_id > $6))"+
+		" ORDER BY age, surname DESC, user_id LIMIT 11", sql)
+	require.Equal(t, []interface{}{22, 22, "Federov", 22, "Federov", 12}, args)
+
+	_, _, err = p.buildGetQuery(schema.GetRequest{Cursor: cur})
+	require.Equal(t, errs.KindValidation, errs.KindOf(err))
+
+	_, _, err = p.buildGetQuery(schema.GetRequest{Sort: sort, Cursor: "garbage"})
+	require.Equal(t, errs.KindValidation, errs.KindOf(err))
+}
+
+func TestBuildCountQuery(t *testing.T) {
+	p := &Postgres{}
+	sql, args, err := p.buildCountQuery(schema.GetRequest{
+		Countries: []string{"RU"},
+		Cursor:    "ignored",
+		Count:     10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "SELECT COUNT(*) FROM userDB WHERE country IN ($1)", sql)
+	require.Equal(t, []interface{}{"RU"}, args)
+}
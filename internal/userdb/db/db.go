@@ -6,6 +6,8 @@ import (
 	"dataservice/internal/schema"
 	"dataservice/internal/userdb"
 	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/Masterminds/squirrel"
@@ -13,8 +15,11 @@ import (
 	"go.uber.org/zap"
 )
 
+const personColumns = "user_id, user_name, surname, age, gender, country, pending, enriched_at, enrichment, version"
+
 type Config struct {
-	QueryTimeout time.Duration
+	QueryTimeout       time.Duration
+	SlowQueryThreshold time.Duration
 }
 
 type Dependencies struct {
@@ -34,44 +39,173 @@ func New(cfg Config, deps Dependencies) userdb.DB {
 	}
 }
 
-func (p *Postgres) AddPersonInfo(ctx context.Context, personInfo schema.PersonInfo) error {
-	_, err := p.deps.PGX.Exec(ctx, `INSERT INTO userDB (user_name, surname, age, gender, country)
-									     VALUES ($1, $2, $3, $4, $5)`, personInfo.Name, personInfo.Surname,
-		personInfo.Age, personInfo.Gender, personInfo.Country)
+func (p *Postgres) AddPersonInfo(ctx context.Context, personInfo schema.PersonInfo) (schema.PersonInfo, error) {
+	ret := schema.PersonInfo{}
+	pending, enrichment := enrichmentArgs(personInfo)
+	err := p.queryRow(ctx, `INSERT INTO userDB (user_name, surname, age, gender, country, pending, enriched_at, enrichment)
+									     VALUES ($1, $2, $3, $4, $5, $6, now(), $7)
+									     RETURNING `+personColumns,
+		[]any{personInfo.Name, personInfo.Surname, personInfo.Age, personInfo.Gender, personInfo.Country,
+			pending, enrichment},
+		&ret.ID, &ret.Name, &ret.Surname, &ret.Age, &ret.Gender, &ret.Country, &ret.Pending, &ret.EnrichedAt,
+		&ret.Enrichment, &ret.Version)
 	if err != nil {
-		p.deps.Log.Error("failed to insert", zap.Error(err))
-		return err
+		p.logError("failed to insert", err)
+		return schema.PersonInfo{}, dbError(err)
 	}
-	p.deps.Log.Info("adding personal info to database")
-	return nil
+	p.deps.Log.Info("adding personal info to database", zap.Int("id", ret.ID))
+	return ret, nil
 }
 
-func (p *Postgres) buildGetQuery(request schema.GetRequest) (string, []interface{}, error) {
-	pred := squirrel.Eq{}
+// buildAddBatchQuery numbers the persons and takes their ids from the
+// sequence before inserting them, so that the inserted rows can be joined
+// back to their numbers: RETURNING alone does not keep the input order.
+func (p *Postgres) buildAddBatchQuery(infos []schema.PersonInfo) (string, []interface{}, error) {
+	values := make([]string, 0, len(infos))
+	args := make([]interface{}, 0, 8*len(infos))
+	for i, info := range infos {
+		pending, enrichment := enrichmentArgs(info)
+		values = append(values, "(?::int, ?::text, ?::text, ?::int, ?::text, ?::text, ?::text[], ?::jsonb)")
+		args = append(args, i+1, info.Name, info.Surname, info.Age, info.Gender, info.Country,
+			pending, enrichment)
+	}
+
+	insert := squirrel.Insert("userDB").
+		Columns("user_id", "user_name", "surname", "age", "gender", "country", "pending", "enriched_at", "enrichment").
+		Select(squirrel.Select("user_id", "user_name", "surname", "age", "gender", "country", "pending", "now()", "enrichment").
+			From("ids")).
+		Suffix("RETURNING " + personColumns)
+
+	b := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	return b.Select("ins.*").
+		Prefix("WITH input (ord, user_name, surname, age, gender, country, pending, enrichment) AS "+
+			"(VALUES "+strings.Join(values, ", ")+"),", args...).
+		Prefix("ids AS MATERIALIZED (SELECT nextval(pg_get_serial_sequence('userdb', 'user_id')) AS user_id, input.* FROM input),").
+		PrefixExpr(squirrel.Expr("ins AS (?)", insert)).
+		From("ins").
+		Join("ids USING (user_id)").
+		OrderBy("ids.ord").
+		ToSql()
+}
+
+// AddPersonInfoBatch inserts the persons in a single statement, returning
+// them in the same order.
+func (p *Postgres) AddPersonInfoBatch(ctx context.Context, infos []schema.PersonInfo) ([]schema.PersonInfo, error) {
+	sql, args, err := p.buildAddBatchQuery(infos)
+	if err != nil {
+		p.deps.Log.Error("failed to build query", zap.Error(err))
+		return nil, err
+	}
+
+	persons, err := p.selectPersons(ctx, sql, args)
+	if err != nil {
+		p.logError("failed to insert", err)
+		return nil, dbError(err)
+	}
+	p.deps.Log.Info("adding personal info to database", zap.Int("count", len(persons)))
+	return persons, nil
+}
+
+// enrichmentArgs returns the pending and enrichment columns of info, empty
+// rather than NULL when unset.
+func enrichmentArgs(info schema.PersonInfo) ([]string, map[string]schema.Enrichment) {
+	pending, enrichment := info.Pending, info.Enrichment
+	if pending == nil {
+		pending = []string{}
+	}
+	if enrichment == nil {
+		enrichment = map[string]schema.Enrichment{}
+	}
+	return pending, enrichment
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func filter(q squirrel.SelectBuilder, request schema.GetRequest) squirrel.SelectBuilder {
+	eq := squirrel.Eq{}
 	if request.Age != 0 {
-		pred["age"] = request.Age
+		eq["age"] = request.Age
 	}
-	if request.Country != "" {
-		pred["country"] = request.Country
+	if len(request.Countries) != 0 {
+		eq["country"] = request.Countries
 	}
 	if request.ID != 0 {
-		pred["user_id"] = request.ID
+		eq["user_id"] = request.ID
 	}
 	if request.Name != "" {
-		pred["user_name"] = request.Name
+		eq["user_name"] = request.Name
 	}
 	if request.Surname != "" {
-		pred["surname"] = request.Surname
+		eq["surname"] = request.Surname
+	}
+	if len(request.Genders) != 0 {
+		eq["gender"] = request.Genders
+	}
+
+	like := make([]squirrel.Sqlizer, 0)
+	if request.NamePrefix != "" {
+		like = append(like, squirrel.ILike{
+			"user_name": likeEscaper.Replace(request.NamePrefix) + "%",
+		})
+	}
+	if request.NameContains != "" {
+		like = append(like, squirrel.ILike{
+			"user_name": "%" + likeEscaper.Replace(request.NameContains) + "%",
+		})
+	}
+	if request.SurnamePrefix != "" {
+		like = append(like, squirrel.ILike{
+			"surname": likeEscaper.Replace(request.SurnamePrefix) + "%",
+		})
+	}
+	if request.SurnameContains != "" {
+		like = append(like, squirrel.ILike{
+			"surname": "%" + likeEscaper.Replace(request.SurnameContains) + "%",
+		})
 	}
-	if request.Gender != "" {
-		pred["gender"] = request.Gender
+
+	if len(eq) != 0 {
+		q = q.Where(eq)
+	}
+	if request.AgeMin != 0 {
+		q = q.Where(squirrel.GtOrEq{"age": request.AgeMin})
+	}
+	if request.AgeMax != 0 {
+		q = q.Where(squirrel.LtOrEq{"age": request.AgeMax})
+	}
+	for _, pred := range like {
+		q = q.Where(pred)
+	}
+	if request.MinConfidence != 0 {
+		q = q.Where("NOT EXISTS (SELECT 1 FROM jsonb_each(enrichment) AS e "+
+			"WHERE (e.value->>'confidence')::float8 < ?)", request.MinConfidence)
+	}
+
+	return q
+}
+
+func (p *Postgres) buildGetQuery(request schema.GetRequest) (string, []interface{}, error) {
+	columns, err := orderColumns(request.Sort)
+	if err != nil {
+		return "", nil, err
 	}
 
 	b := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
-	q := b.Select("user_id, user_name, surname, age, gender, country").
-		From("userDB").Where(pred)
+	q := filter(b.Select(personColumns).From("userDB"), request)
+
+	if request.Cursor != "" {
+		values, err := decodeCursor(request.Cursor, request.Sort, columns)
+		if err != nil {
+			return "", nil, err
+		}
+		q = q.Where(keysetPredicate(columns, values))
+	}
+
+	q = q.OrderBy(orderBy(columns)...)
+
+	// One extra row tells whether there is a next page.
 	if request.Count != 0 {
-		q = q.Limit(uint64(request.Count))
+		q = q.Limit(uint64(request.Count) + 1)
 	}
 	if request.Offset != 0 {
 		q = q.Offset(uint64(request.Offset))
@@ -80,62 +214,304 @@ func (p *Postgres) buildGetQuery(request schema.GetRequest) (string, []interface
 	return q.ToSql()
 }
 
-func (p *Postgres) GetPersonInfo(ctx context.Context, request schema.GetRequest) ([]schema.PersonInfo, error) {
+func (p *Postgres) buildCountQuery(request schema.GetRequest) (string, []interface{}, error) {
+	b := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	return filter(b.Select("COUNT(*)").From("userDB"), request).ToSql()
+}
+
+func (p *Postgres) GetPersonInfo(ctx context.Context, request schema.GetRequest) (schema.GetResponse, error) {
 	sql, args, err := p.buildGetQuery(request)
 	if err != nil {
 		p.deps.Log.Error("failed to build query", zap.Error(err))
-		return nil, err
+		return schema.GetResponse{}, err
 	}
 
 	p.deps.Log.Debug("select query", zap.String("query", sql), zap.Any("args", args))
 
-	res, err := p.deps.PGX.Query(ctx, sql, args...)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, userdb.ErrNotFound
-	} else if err != nil {
-		p.deps.Log.Error("failed to select", zap.Error(err))
-		return nil, err
+	persons, err := p.selectPersons(ctx, sql, args)
+	if err != nil {
+		p.logError("failed to select", err)
+		return schema.GetResponse{}, dbError(err)
 	}
 
-	defer res.Close()
-	ret := make([]schema.PersonInfo, 0)
-	for res.Next() {
-		cur := schema.PersonInfo{}
-		err := res.Scan(&cur.ID, &cur.Name, &cur.Surname, &cur.Age, &cur.Gender, &cur.Country)
+	if request.ID != 0 && len(persons) == 0 {
+		return schema.GetResponse{}, userdb.ErrNotFound
+	}
+
+	ret := schema.GetResponse{Persons: persons}
+	if request.Count != 0 && len(persons) > request.Count {
+		ret.Persons = persons[:request.Count]
+
+		columns, _ := orderColumns(request.Sort)
+		ret.NextCursor, err = encodeCursor(request.Sort, columns, ret.Persons[request.Count-1])
 		if err != nil {
-			p.deps.Log.Error("failed to scan rows", zap.Error(err))
-			return nil, err
+			p.deps.Log.Error("failed to encode cursor", zap.Error(err))
+			return schema.GetResponse{}, err
 		}
+	}
 
-		ret = append(ret, cur)
+	if request.Total {
+		total, err := p.countPersonInfo(ctx, request)
+		if err != nil {
+			return schema.GetResponse{}, err
+		}
+		ret.Total = &total
 	}
 
 	return ret, nil
 }
 
+func (p *Postgres) selectPersons(ctx context.Context, sql string, args []any) ([]schema.PersonInfo, error) {
+	persons := make([]schema.PersonInfo, 0)
+	err := p.query(ctx, sql, args, func(rows pgx.Rows) error {
+		cur := schema.PersonInfo{}
+		err := rows.Scan(&cur.ID, &cur.Name, &cur.Surname, &cur.Age, &cur.Gender, &cur.Country,
+			&cur.Pending, &cur.EnrichedAt, &cur.Enrichment, &cur.Version)
+		if err != nil {
+			return err
+		}
+
+		persons = append(persons, cur)
+		return nil
+	})
+	return persons, err
+}
+
+func (p *Postgres) countPersonInfo(ctx context.Context, request schema.GetRequest) (int, error) {
+	sql, args, err := p.buildCountQuery(request)
+	if err != nil {
+		p.deps.Log.Error("failed to build query", zap.Error(err))
+		return 0, err
+	}
+
+	p.deps.Log.Debug("count query", zap.String("query", sql), zap.Any("args", args))
+
+	total := 0
+	if err := p.queryRow(ctx, sql, args, &total); err != nil {
+		p.logError("failed to count", err)
+		return 0, dbError(err)
+	}
+	return total, nil
+}
+
 func (p *Postgres) DeletePersonInfo(ctx context.Context, id int) error {
-	_, err := p.deps.PGX.Exec(ctx, `DELETE FROM userDB WHERE user_id = $1`, id)
-	if errors.Is(err, pgx.ErrNoRows) {
+	tag, err := p.exec(ctx, `DELETE FROM userDB WHERE user_id = $1`, id)
+	if err != nil {
+		p.logError("failed to delete", err)
+		return dbError(err)
+	}
+	if tag.RowsAffected() == 0 {
 		return userdb.ErrNotFound
-	} else if err != nil {
-		p.deps.Log.Error("failed to delete", zap.Error(err))
-		return err
 	}
 	p.deps.Log.Info("deleting personal info from the database")
 	return nil
 }
 
 func (p *Postgres) UpdatePersonInfo(ctx context.Context, info schema.PersonInfo) error {
-	_, err := p.deps.PGX.Exec(ctx, `UPDATE userDB 
-									SET user_name = $1, surname = $2, age = $3, gender = $4, country = $5
-									WHERE user_id = $6`,
-		info.Name, info.Surname, info.Age, info.Gender, info.Country, info.ID)
-	if errors.Is(err, pgx.ErrNoRows) {
+	tag, err := p.exec(ctx, `UPDATE userDB 
+									SET user_name = $1, surname = $2, age = $3, gender = $4, country = $5, pending = '{}',
+										enrichment = `+callerEnrichment("$6")+`, version = version + 1
+									WHERE user_id = $7`,
+		info.Name, info.Surname, info.Age, info.Gender, info.Country, setAttributes(info), info.ID)
+	if err != nil {
+		p.logError("failed to update", err)
+		return dbError(err)
+	}
+	if tag.RowsAffected() == 0 {
 		return userdb.ErrNotFound
+	}
+	p.deps.Log.Info("updating personal info in the database")
+	return nil
+}
+
+// setAttributes lists the attributes info has a value for.
+func setAttributes(info schema.PersonInfo) []string {
+	ret := make([]string, 0, len(schema.Attributes))
+	for _, a := range schema.Attributes {
+		if !slices.Contains(info.Missing(), a.Name) {
+			ret = append(ret, a.Name)
+		}
+	}
+	return ret
+}
+
+// pendingWithout is the pending column less the attributes of a text[]
+// parameter.
+const pendingWithout = "ARRAY(SELECT a FROM unnest(pending) AS a WHERE a <> ALL(?::text[]))"
+
+// callerEnrichment builds the enrichment entries marking the attributes of
+// the text[] parameter arg as set by the caller.
+func callerEnrichment(arg string) string {
+	return "(SELECT coalesce(jsonb_object_agg(a, jsonb_build_object(" +
+		"'provider', '" + schema.ProviderCaller + "', 'count', 0, 'fetched_at', now())), '{}') " +
+		"FROM unnest(" + arg + "::text[]) AS a)"
+}
+
+func (p *Postgres) buildPatchQuery(id int, patch schema.PersonPatch) (string, []interface{}, error) {
+	b := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	q := b.Update("userDB").
+		Where(squirrel.Eq{"user_id": id}).
+		Suffix("RETURNING " + personColumns)
+
+	if patch.Name != nil {
+		q = q.Set("user_name", *patch.Name)
+	}
+	if patch.Surname != nil {
+		q = q.Set("surname", *patch.Surname)
+	}
+
+	// Attributes set by hand are no longer waiting for enrichment and are
+	// marked as coming from the caller.
+	resolved := make([]string, 0, 3)
+	if patch.Age != nil {
+		q = q.Set("age", *patch.Age)
+		resolved = append(resolved, schema.AttrAge)
+	}
+	if patch.Gender != nil {
+		q = q.Set("gender", *patch.Gender)
+		resolved = append(resolved, schema.AttrGender)
+	}
+	if patch.Country != nil {
+		q = q.Set("country", *patch.Country)
+		resolved = append(resolved, schema.AttrCountry)
+	}
+	// Removed attributes are emptied and left pending, for the worker to
+	// look them up again.
+	cleared := append([]string{}, patch.Clear...)
+	for _, attr := range cleared {
+		if a, ok := schema.LookupAttribute(attr); ok {
+			q = q.Set(a.Column, a.Empty[0])
+		}
+	}
+	if len(resolved)+len(cleared) != 0 {
+		touched := append(slices.Clone(resolved), cleared...)
+		q = q.Set("pending", squirrel.Expr("array_cat("+pendingWithout+", ?::text[])", touched, cleared))
+		q = q.Set("enrichment", squirrel.Expr("(enrichment - ?::text[]) || "+callerEnrichment("?"), cleared, resolved))
+	}
+
+	return q.Set("version", squirrel.Expr("version + 1")).ToSql()
+}
+
+func (p *Postgres) PatchPersonInfo(ctx context.Context, id int, patch schema.PersonPatch) (schema.PersonInfo, error) {
+	sql, args, err := p.buildPatchQuery(id, patch)
+	if err != nil {
+		p.deps.Log.Error("failed to build query", zap.Error(err))
+		return schema.PersonInfo{}, err
+	}
+
+	p.deps.Log.Debug("patch query", zap.String("query", sql), zap.Any("args", args))
+
+	ret := schema.PersonInfo{}
+	err = p.queryRow(ctx, sql, args,
+		&ret.ID, &ret.Name, &ret.Surname, &ret.Age, &ret.Gender, &ret.Country, &ret.Pending, &ret.EnrichedAt,
+		&ret.Enrichment, &ret.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return schema.PersonInfo{}, userdb.ErrNotFound
 	} else if err != nil {
-		p.deps.Log.Error("failed to update", zap.Error(err))
+		p.logError("failed to patch", err)
+		return schema.PersonInfo{}, dbError(err)
+	}
+	p.deps.Log.Info("patching personal info in the database")
+	return ret, nil
+}
+
+func (p *Postgres) buildStaleQuery(request schema.StaleRequest) (string, []interface{}, error) {
+	missing := squirrel.Or{}
+	for _, a := range schema.Attributes {
+		if len(a.Empty) == 1 {
+			missing = append(missing, squirrel.Eq{a.Column: a.Empty[0]})
+		} else {
+			missing = append(missing, squirrel.Eq{a.Column: a.Empty})
+		}
+	}
+
+	b := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	q := b.Select(personColumns).From("userDB").
+		Where(squirrel.Or{
+			squirrel.Expr("cardinality(pending) > 0"),
+			squirrel.Eq{"enriched_at": nil},
+			squirrel.Lt{"enriched_at": request.StaleBefore},
+			squirrel.And{
+				missing,
+				squirrel.Lt{"enriched_at": request.RetryBefore},
+			},
+		}).
+		OrderBy("enriched_at NULLS FIRST", "user_id")
+
+	if request.Count != 0 {
+		q = q.Limit(uint64(request.Count))
+	}
+	return q.ToSql()
+}
+
+func (p *Postgres) GetStalePersonInfo(ctx context.Context, request schema.StaleRequest) ([]schema.PersonInfo, error) {
+	sql, args, err := p.buildStaleQuery(request)
+	if err != nil {
+		p.deps.Log.Error("failed to build query", zap.Error(err))
+		return nil, err
+	}
+
+	p.deps.Log.Debug("stale query", zap.String("query", sql), zap.Any("args", args))
+
+	persons, err := p.selectPersons(ctx, sql, args)
+	if err != nil {
+		p.logError("failed to select", err)
+		return nil, dbError(err)
+	}
+	return persons, nil
+}
+
+func (p *Postgres) buildEnrichmentQuery(update schema.EnrichmentUpdate) (string, []interface{}, error) {
+	b := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	q := b.Update("userDB")
+
+	// Only the attributes looked up are written, leaving the rest as they
+	// are. Each leaves pending, failed ones are put back.
+	looked := append([]string{}, update.Failed...)
+	for _, a := range schema.Attributes {
+		if value, ok := update.Values[a.Name]; ok {
+			q = q.Set(a.Column, value)
+			looked = append(looked, a.Name)
+		}
+	}
+
+	failed := update.Failed
+	if failed == nil {
+		failed = []string{}
+	}
+	enrichment := update.Enrichment
+	if enrichment == nil {
+		enrichment = map[string]schema.Enrichment{}
+	}
+
+	return q.
+		Set("pending", squirrel.Expr("array_cat("+pendingWithout+", ?::text[])", looked, failed)).
+		Set("enrichment", squirrel.Expr("enrichment || ?::jsonb", enrichment)).
+		Set("enriched_at", squirrel.Expr("now()")).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(squirrel.Eq{"user_id": update.ID, "version": update.Version}).
+		ToSql()
+}
+
+// UpdateEnrichment stores the outcome of re-enriching a person, unless it
+// was changed or deleted since it was read, which is reported as
+// userdb.ErrNotFound.
+func (p *Postgres) UpdateEnrichment(ctx context.Context, update schema.EnrichmentUpdate) error {
+	sql, args, err := p.buildEnrichmentQuery(update)
+	if err != nil {
+		p.deps.Log.Error("failed to build query", zap.Error(err))
 		return err
 	}
-	p.deps.Log.Info("updating personal info in the database")
+
+	p.deps.Log.Debug("enrichment query", zap.String("query", sql), zap.Any("args", args))
+
+	tag, err := p.exec(ctx, sql, args...)
+	if err != nil {
+		p.logError("failed to update enrichment", err)
+		return dbError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return userdb.ErrNotFound
+	}
 	return nil
 }
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"dataservice/internal/errs"
+	"dataservice/internal/schema"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildGetQuery(t *testing.T) {
+	const selectAll = "SELECT " + personColumns + " FROM userDB"
+
+	tests := []struct {
+		name string
+		req  schema.GetRequest
+		sql  string
+		args []interface{}
+	}{
+		{
+			name: "no filters",
+			req:  schema.GetRequest{},
+			sql:  selectAll + " ORDER BY user_id",
+		},
+		{
+			name: "equality",
+			req:  schema.GetRequest{ID: 12, Name: "Dmitry"},
+			sql:  selectAll + " WHERE user_id = $1 AND user_name = $2 ORDER BY user_id",
+			args: []interface{}{12, "Dmitry"},
+		},
+		{
+			name: "age range",
+			req:  schema.GetRequest{AgeMin: 18, AgeMax: 30},
+			sql:  selectAll + " WHERE age >= $1 AND age <= $2 ORDER BY user_id",
+			args: []interface{}{18, 30},
+		},
+		{
+			name: "sets",
+			req: schema.GetRequest{
+				Countries: []string{"RU", "UA", "KZ"},
+				Genders:   []string{"male"},
+			},
+			sql:  selectAll + " WHERE country IN ($1,$2,$3) AND gender IN ($4) ORDER BY user_id",
+			args: []interface{}{"RU", "UA", "KZ", "male"},
+		},
+		{
+			name: "patterns",
+			req:  schema.GetRequest{NamePrefix: "dm", SurnameContains: "50%_off"},
+			sql:  selectAll + " WHERE user_name ILIKE $1 AND surname ILIKE $2 ORDER BY user_id",
+			args: []interface{}{"dm%", `%50\%\_off%`},
+		},
+		{
+			name: "min confidence",
+			req:  schema.GetRequest{MinConfidence: 0.8},
+			sql: selectAll + " WHERE NOT EXISTS (SELECT 1 FROM jsonb_each(enrichment) AS e " +
+				"WHERE (e.value->>'confidence')::float8 < $1) ORDER BY user_id",
+			args: []interface{}{0.8},
+		},
+		{
+			name: "combined with paging",
+			req: schema.GetRequest{
+				Countries:    []string{"RU"},
+				AgeMin:       18,
+				NameContains: "mit",
+				Count:        10,
+				Offset:       20,
+			},
+			sql: selectAll + " WHERE country IN ($1) AND age >= $2 AND user_name ILIKE $3" +
+				" ORDER BY user_id LIMIT 11 OFFSET 20",
+			args: []interface{}{"RU", 18, "%mit%"},
+		},
+		{
+			name: "sorted",
+			req: schema.GetRequest{
+				Sort: []schema.SortField{
+					{Field: "age"},
+					{Field: "surname", Desc: true},
+				},
+			},
+			sql: selectAll + " ORDER BY age, surname DESC, user_id",
+		},
+		{
+			name: "sorted by id",
+			req: schema.GetRequest{
+				Sort: []schema.SortField{
+					{Field: "id", Desc: true},
+				},
+			},
+			sql: selectAll + " ORDER BY user_id DESC",
+		},
+	}
+
+	p := &Postgres{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := p.buildGetQuery(tt.req)
+			require.NoError(t, err)
+			require.Equal(t, tt.sql, sql)
+			require.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func TestBuildGetQueryUnknownSort(t *testing.T) {
+	p := &Postgres{}
+	_, _, err := p.buildGetQuery(schema.GetRequest{
+		Sort: []schema.SortField{{Field: "user_id; DROP TABLE userDB"}},
+	})
+	require.Equal(t, errs.KindValidation, errs.KindOf(err))
+}
+
+func TestBuildPatchQuery(t *testing.T) {
+	age := 30
+	country := "RU"
+
+	p := &Postgres{}
+	sql, args, err := p.buildPatchQuery(12, schema.PersonPatch{
+		Age:     &age,
+		Country: &country,
+		Clear:   []string{"gender"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "UPDATE userDB SET age = $1, country = $2, gender = $3, "+
+		"pending = array_cat(ARRAY(SELECT a FROM unnest(pending) AS a WHERE a <> ALL($4::text[])), $5::text[]), "+
+		"enrichment = (enrichment - $6::text[]) || "+callerEnrichment("$7")+", "+
+		"version = version + 1 WHERE user_id = $8 "+
+		"RETURNING "+personColumns, sql)
+	require.Equal(t, []interface{}{
+		age, country, "",
+		[]string{"age", "country", "gender"}, []string{"gender"},
+		[]string{"gender"}, []string{"age", "country"}, 12,
+	}, args)
+
+	sql, args, err = p.buildPatchQuery(12, schema.PersonPatch{
+		Surname: &country,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "UPDATE userDB SET surname = $1, version = version + 1 WHERE user_id = $2 "+
+		"RETURNING "+personColumns, sql)
+	require.Equal(t, []interface{}{country, 12}, args)
+}
+
+func TestBuildStaleQuery(t *testing.T) {
+	staleBefore := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	retryBefore := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+
+	p := &Postgres{}
+	sql, args, err := p.buildStaleQuery(schema.StaleRequest{
+		StaleBefore: staleBefore,
+		RetryBefore: retryBefore,
+		Count:       100,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "SELECT "+personColumns+" FROM userDB "+
+		"WHERE (cardinality(pending) > 0 OR enriched_at IS NULL OR enriched_at < $1 OR "+
+		"((age = $2 OR gender = $3 OR country IN ($4,$5)) AND enriched_at < $6)) "+
+		"ORDER BY enriched_at NULLS FIRST, user_id LIMIT 100", sql)
+	require.Equal(t, []interface{}{staleBefore, 0, "", "", "unknown", retryBefore}, args)
+}
+
+func TestBuildAddBatchQuery(t *testing.T) {
+	p := &Postgres{}
+	sql, args, err := p.buildAddBatchQuery([]schema.PersonInfo{
+		{Name: "Dmitry", Surname: "Ushakov", Age: 22, Gender: "male", Country: "RU"},
+		{Name: "Anna", Surname: "Ivanova", Gender: "female", Pending: []string{"age"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "WITH input (ord, user_name, surname, age, gender, country, pending, enrichment) AS (VALUES "+
+		"($1::int, $2::text, $3::text, $4::int, $5::text, $6::text, $7::text[], $8::jsonb), "+
+		"($9::int, $10::text, $11::text, $12::int, $13::text, $14::text, $15::text[], $16::jsonb)), "+
+		"ids AS MATERIALIZED (SELECT nextval(pg_get_serial_sequence('userdb', 'user_id')) AS user_id, input.* FROM input), "+
+		"ins AS (INSERT INTO userDB (user_id,user_name,surname,age,gender,country,pending,enriched_at,enrichment) "+
+		"SELECT user_id, user_name, surname, age, gender, country, pending, now(), enrichment FROM ids "+
+		"RETURNING "+personColumns+") "+
+		"SELECT ins.* FROM ins JOIN ids USING (user_id) ORDER BY ids.ord", sql)
+	require.Equal(t, []interface{}{
+		1, "Dmitry", "Ushakov", 22, "male", "RU", []string{}, map[string]schema.Enrichment{},
+		2, "Anna", "Ivanova", 0, "female", "", []string{"age"}, map[string]schema.Enrichment{},
+	}, args)
+}
+
+func TestBuildEnrichmentQuery(t *testing.T) {
+	nationalize := schema.Enrichment{Provider: "nationalize", Count: 30}
+
+	p := &Postgres{}
+	sql, args, err := p.buildEnrichmentQuery(schema.EnrichmentUpdate{
+		ID: 7, Version: 2,
+		Values:     map[string]any{schema.AttrCountry: "RU"},
+		Enrichment: map[string]schema.Enrichment{schema.AttrCountry: nationalize},
+		Failed:     []string{schema.AttrAge},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "UPDATE userDB SET country = $1, "+
+		"pending = array_cat(ARRAY(SELECT a FROM unnest(pending) AS a WHERE a <> ALL($2::text[])), $3::text[]), "+
+		"enrichment = enrichment || $4::jsonb, enriched_at = now(), version = version + 1 "+
+		"WHERE user_id = $5 AND version = $6", sql)
+	require.Equal(t, []interface{}{
+		"RU", []string{"age", "country"}, []string{"age"},
+		map[string]schema.Enrichment{schema.AttrCountry: nationalize}, 7, 2,
+	}, args)
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"dataservice/internal/errs"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+const (
+	pgNotNullViolation     = "23502"
+	pgUniqueViolation      = "23505"
+	pgCheckViolation       = "23514"
+	pgStringDataTruncation = "22001"
+	pgQueryCanceled        = "57014"
+)
+
+// logError logs a failed statement, quietly when the caller gave up on it.
+func (p *Postgres) logError(msg string, err error) {
+	if errors.Is(err, context.Canceled) {
+		p.deps.Log.Debug(msg, zap.Error(err))
+		return
+	}
+	p.deps.Log.Error(msg, zap.Error(err))
+}
+
+func (p *Postgres) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.cfg.QueryTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.cfg.QueryTimeout)
+}
+
+func (p *Postgres) logSlow(start time.Time, sql string, args []any) {
+	elapsed := time.Since(start)
+	if p.cfg.SlowQueryThreshold == 0 || elapsed < p.cfg.SlowQueryThreshold {
+		return
+	}
+
+	p.deps.Log.Warn("slow query",
+		zap.Duration("elapsed", elapsed),
+		zap.String("query", sql),
+		zap.Any("args", args))
+}
+
+func (p *Postgres) exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.logSlow(time.Now(), sql, args)
+
+	return p.deps.PGX.Exec(ctx, sql, args...)
+}
+
+func (p *Postgres) queryRow(ctx context.Context, sql string, args []any, dest ...any) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.logSlow(time.Now(), sql, args)
+
+	return p.deps.PGX.QueryRow(ctx, sql, args...).Scan(dest...)
+}
+
+// query calls scan for every returned row. The deadline covers reading all
+// of the rows.
+func (p *Postgres) query(ctx context.Context, sql string, args []any, scan func(pgx.Rows) error) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.logSlow(time.Now(), sql, args)
+
+	rows, err := p.deps.PGX.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// dbError classifies errors returned by pgx: server-side errors are
+// classified by SQLSTATE, anything else means the database could not be
+// reached in time, unless the caller gave up first.
+func dbError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return errs.E(errs.KindCanceled, err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return errs.E(errs.KindTimeout, err)
+	}
+
+	var scanErr pgx.ScanArgError
+	if errors.As(err, &scanErr) {
+		return errs.E(errs.KindInternal, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return errs.E(errs.KindUnavailable, err)
+	}
+
+	switch pgErr.Code {
+	case pgQueryCanceled:
+		return errs.E(errs.KindTimeout, err)
+	case pgUniqueViolation:
+		return errs.E(errs.KindConflict, err)
+	case pgNotNullViolation, pgCheckViolation, pgStringDataTruncation:
+		return errs.E(errs.KindValidation, err)
+	}
+	return errs.E(errs.KindInternal, err)
+}
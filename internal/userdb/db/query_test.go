@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"dataservice/internal/errs"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind errs.Kind
+	}{
+		{err: fmt.Errorf("query: %w", context.DeadlineExceeded), kind: errs.KindTimeout},
+		{err: &pgconn.PgError{Code: pgQueryCanceled}, kind: errs.KindTimeout},
+		{err: &pgconn.PgError{Code: pgUniqueViolation}, kind: errs.KindConflict},
+		{err: &pgconn.PgError{Code: pgStringDataTruncation}, kind: errs.KindValidation},
+		{err: &pgconn.PgError{Code: "42P01"}, kind: errs.KindInternal},
+		{err: errors.New("connection refused"), kind: errs.KindUnavailable},
+		{err: fmt.Errorf("query: %w", context.Canceled), kind: errs.KindCanceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			require.Equal(t, tt.kind, errs.KindOf(dbError(tt.err)))
+		})
+	}
+}
@@ -41,11 +41,12 @@ func (m *MockDB) EXPECT() *MockDBMockRecorder {
 }
 
 // AddPersonInfo mocks base method.
-func (m *MockDB) AddPersonInfo(arg0 context.Context, arg1 schema.PersonInfo) error {
+func (m *MockDB) AddPersonInfo(arg0 context.Context, arg1 schema.PersonInfo) (schema.PersonInfo, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AddPersonInfo", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(schema.PersonInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // AddPersonInfo indicates an expected call of AddPersonInfo.
@@ -54,6 +55,21 @@ func (mr *MockDBMockRecorder) AddPersonInfo(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPersonInfo", reflect.TypeOf((*MockDB)(nil).AddPersonInfo), arg0, arg1)
 }
 
+// AddPersonInfoBatch mocks base method.
+func (m *MockDB) AddPersonInfoBatch(arg0 context.Context, arg1 []schema.PersonInfo) ([]schema.PersonInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPersonInfoBatch", arg0, arg1)
+	ret0, _ := ret[0].([]schema.PersonInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddPersonInfoBatch indicates an expected call of AddPersonInfoBatch.
+func (mr *MockDBMockRecorder) AddPersonInfoBatch(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPersonInfoBatch", reflect.TypeOf((*MockDB)(nil).AddPersonInfoBatch), arg0, arg1)
+}
+
 // DeletePersonInfo mocks base method.
 func (m *MockDB) DeletePersonInfo(arg0 context.Context, arg1 int) error {
 	m.ctrl.T.Helper()
@@ -69,10 +85,10 @@ func (mr *MockDBMockRecorder) DeletePersonInfo(arg0, arg1 any) *gomock.Call {
 }
 
 // GetPersonInfo mocks base method.
-func (m *MockDB) GetPersonInfo(arg0 context.Context, arg1 schema.GetRequest) ([]schema.PersonInfo, error) {
+func (m *MockDB) GetPersonInfo(arg0 context.Context, arg1 schema.GetRequest) (schema.GetResponse, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetPersonInfo", arg0, arg1)
-	ret0, _ := ret[0].([]schema.PersonInfo)
+	ret0, _ := ret[0].(schema.GetResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -83,6 +99,50 @@ func (mr *MockDBMockRecorder) GetPersonInfo(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersonInfo", reflect.TypeOf((*MockDB)(nil).GetPersonInfo), arg0, arg1)
 }
 
+// GetStalePersonInfo mocks base method.
+func (m *MockDB) GetStalePersonInfo(arg0 context.Context, arg1 schema.StaleRequest) ([]schema.PersonInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStalePersonInfo", arg0, arg1)
+	ret0, _ := ret[0].([]schema.PersonInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStalePersonInfo indicates an expected call of GetStalePersonInfo.
+func (mr *MockDBMockRecorder) GetStalePersonInfo(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStalePersonInfo", reflect.TypeOf((*MockDB)(nil).GetStalePersonInfo), arg0, arg1)
+}
+
+// PatchPersonInfo mocks base method.
+func (m *MockDB) PatchPersonInfo(arg0 context.Context, arg1 int, arg2 schema.PersonPatch) (schema.PersonInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchPersonInfo", arg0, arg1, arg2)
+	ret0, _ := ret[0].(schema.PersonInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchPersonInfo indicates an expected call of PatchPersonInfo.
+func (mr *MockDBMockRecorder) PatchPersonInfo(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchPersonInfo", reflect.TypeOf((*MockDB)(nil).PatchPersonInfo), arg0, arg1, arg2)
+}
+
+// UpdateEnrichment mocks base method.
+func (m *MockDB) UpdateEnrichment(arg0 context.Context, arg1 schema.EnrichmentUpdate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEnrichment", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateEnrichment indicates an expected call of UpdateEnrichment.
+func (mr *MockDBMockRecorder) UpdateEnrichment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEnrichment", reflect.TypeOf((*MockDB)(nil).UpdateEnrichment), arg0, arg1)
+}
+
 // UpdatePersonInfo mocks base method.
 func (m *MockDB) UpdatePersonInfo(arg0 context.Context, arg1 schema.PersonInfo) error {
 	m.ctrl.T.Helper()
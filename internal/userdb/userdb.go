@@ -2,16 +2,20 @@ package userdb
 
 import (
 	"context"
+	"dataservice/internal/errs"
 	"dataservice/internal/schema"
-	"errors"
 )
 
-var ErrNotFound = errors.New("not found")
+var ErrNotFound = errs.New(errs.KindNotFound, "not found")
 
 //go:generate mockgen -package userdb -destination db_mock.go . DB
 type DB interface {
-	AddPersonInfo(ctx context.Context, info schema.PersonInfo) error
-	GetPersonInfo(ctx context.Context, req schema.GetRequest) ([]schema.PersonInfo, error)
+	AddPersonInfo(ctx context.Context, info schema.PersonInfo) (schema.PersonInfo, error)
+	AddPersonInfoBatch(ctx context.Context, infos []schema.PersonInfo) ([]schema.PersonInfo, error)
+	GetPersonInfo(ctx context.Context, req schema.GetRequest) (schema.GetResponse, error)
 	DeletePersonInfo(ctx context.Context, id int) error
 	UpdatePersonInfo(ctx context.Context, info schema.PersonInfo) error
+	PatchPersonInfo(ctx context.Context, id int, patch schema.PersonPatch) (schema.PersonInfo, error)
+	GetStalePersonInfo(ctx context.Context, req schema.StaleRequest) ([]schema.PersonInfo, error)
+	UpdateEnrichment(ctx context.Context, update schema.EnrichmentUpdate) error
 }
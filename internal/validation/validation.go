@@ -0,0 +1,108 @@
+package validation
+
+import (
+	"dataservice/internal/errs"
+	"dataservice/internal/schema"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, f := range e {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// country also accepts the value stored when no country is known, so
+	// that stored persons can be sent back as they are.
+	_ = v.RegisterValidation("country", func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		return s == schema.UnknownCountry || v.Var(s, "iso3166_1_alpha2") == nil
+	})
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// Struct checks v against its `validate` tags and returns a validation error
+// listing every offending field.
+func Struct(v any) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	ret := make(Errors, 0, len(verrs))
+	for _, f := range verrs {
+		ret = append(ret, FieldError{
+			Field:   field(f),
+			Rule:    f.Tag(),
+			Message: message(f),
+		})
+	}
+	return errs.E(errs.KindValidation, ret)
+}
+
+// field names f by its path from the validated struct, such as
+// persons[0].name for nested values.
+func field(f validator.FieldError) string {
+	if _, path, ok := strings.Cut(f.Namespace(), "."); ok {
+		return path
+	}
+	return f.Field()
+}
+
+func message(f validator.FieldError) string {
+	isString := f.Kind() == reflect.String
+	switch f.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		if isString {
+			return fmt.Sprintf("must be at least %s characters long", f.Param())
+		}
+		return fmt.Sprintf("must be at least %s", f.Param())
+	case "max":
+		if isString {
+			return fmt.Sprintf("must be at most %s characters long", f.Param())
+		}
+		return fmt.Sprintf("must be at most %s", f.Param())
+	case "alphaunicode":
+		return "must contain only letters"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(f.Param(), " ", ", ")
+	case "iso3166_1_alpha2":
+		return "must be an ISO 3166-1 alpha-2 country code"
+	case "country":
+		return "must be an ISO 3166-1 alpha-2 country code or " + schema.UnknownCountry
+	}
+	return "is invalid"
+}
@@ -0,0 +1,100 @@
+package validation
+
+import (
+	"dataservice/internal/errs"
+	"dataservice/internal/schema"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStruct(t *testing.T) {
+	age := 151
+	gender := "robot"
+	country := "RUS"
+
+	tests := []struct {
+		name   string
+		value  any
+		fields Errors
+	}{
+		{
+			name:  "valid put",
+			value: schema.PutRequest{Name: "Дмитрий", Surname: "Federov"},
+		},
+		{
+			name:  "empty put",
+			value: schema.PutRequest{},
+			fields: Errors{
+				{Field: "name", Rule: "required", Message: "is required"},
+				{Field: "surname", Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			name: "too long name",
+			value: schema.PutRequest{
+				Name:    "Dmitryyyyyyyyyyyyyyyyyyyyyyyyyy",
+				Surname: "Federov",
+			},
+			fields: Errors{
+				{Field: "name", Rule: "max", Message: "must be at most 30 characters long"},
+			},
+		},
+		{
+			name: "invalid batch",
+			value: schema.PutBatchRequest{Persons: []schema.PutRequest{
+				{Name: "Dmitry", Surname: "Federov"},
+				{Name: "Dmitry"},
+			}},
+			fields: Errors{
+				{Field: "persons[1].surname", Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			name:   "empty batch",
+			value:  schema.PutBatchRequest{},
+			fields: Errors{{Field: "persons", Rule: "required", Message: "is required"}},
+		},
+		{
+			name:  "valid info",
+			value: schema.PersonInfo{Name: "Dmitry", Surname: "Federov", Age: 22, Gender: "male", Country: "RU"},
+		},
+		{
+			name:  "info without a known country",
+			value: schema.PersonInfo{Name: "Dmitry", Surname: "Federov", Country: schema.UnknownCountry},
+		},
+		{
+			name:  "invalid info",
+			value: schema.PersonInfo{Name: "Dmitry", Surname: "Federov", Country: "Russia"},
+			fields: Errors{
+				{Field: "country", Rule: "country", Message: "must be an ISO 3166-1 alpha-2 country code or unknown"},
+			},
+		},
+		{
+			name:  "invalid patch",
+			value: schema.PersonPatch{Age: &age, Gender: &gender, Country: &country},
+			fields: Errors{
+				{Field: "age", Rule: "max", Message: "must be at most 150"},
+				{Field: "gender", Rule: "oneof", Message: "must be one of: male, female"},
+				{Field: "country", Rule: "iso3166_1_alpha2", Message: "must be an ISO 3166-1 alpha-2 country code"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(tt.value)
+			if tt.fields == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Equal(t, errs.KindValidation, errs.KindOf(err))
+
+			var fields Errors
+			require.True(t, errors.As(err, &fields))
+			require.Equal(t, tt.fields, fields)
+		})
+	}
+}
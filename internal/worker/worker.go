@@ -0,0 +1,222 @@
+package worker
+
+import (
+	"context"
+	"dataservice/internal/api"
+	"dataservice/internal/schema"
+	"dataservice/internal/userdb"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultInterval   = time.Minute
+	defaultBatchSize  = 100
+	defaultStaleAfter = 30 * 24 * time.Hour
+	defaultRetryAfter = 24 * time.Hour
+	defaultRate       = 1
+	defaultTimeout    = time.Second
+)
+
+// Config sets how often the worker scans for stale persons and how hard it
+// may query the providers: at most Rate lookups per second.
+type Config struct {
+	Interval time.Duration
+	// BatchSize caps the number of persons re-enriched per scan.
+	BatchSize int
+	// StaleAfter is the age after which every attribute is looked up again.
+	StaleAfter time.Duration
+	// RetryAfter is the age after which missing attributes are looked up
+	// again.
+	RetryAfter time.Duration
+	Rate       float64
+	Timeout    time.Duration
+	// Localize looks up age and gender for the stored country, as the
+	// manager does.
+	Localize   bool
+	MinSamples int
+}
+
+type Dependencies struct {
+	Providers []api.Provider
+	DB        userdb.DB
+	Log       *zap.Logger
+}
+
+// Worker fills in the attributes of persons stored while a provider was
+// failing or knew nothing about the name, and refreshes stale ones.
+type Worker struct {
+	cfg       Config
+	deps      Dependencies
+	log       *zap.Logger
+	limiter   *rate.Limiter
+	providers map[string]api.Provider
+
+	now func() time.Time
+}
+
+func New(cfg Config, deps Dependencies) *Worker {
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.StaleAfter == 0 {
+		cfg.StaleAfter = defaultStaleAfter
+	}
+	if cfg.RetryAfter == 0 {
+		cfg.RetryAfter = defaultRetryAfter
+	}
+	if cfg.Rate == 0 {
+		cfg.Rate = defaultRate
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MinSamples == 0 {
+		cfg.MinSamples = api.DefaultMinSamples
+	}
+
+	providers := make(map[string]api.Provider, len(deps.Providers))
+	for _, p := range deps.Providers {
+		providers[p.Attribute()] = p
+	}
+
+	return &Worker{
+		cfg:       cfg,
+		deps:      deps,
+		log:       deps.Log.Named("worker"),
+		limiter:   rate.NewLimiter(rate.Limit(cfg.Rate), 1),
+		providers: providers,
+		now:       time.Now,
+	}
+}
+
+// Run scans every Interval until ctx is done.
+func (w *Worker) Run(ctx context.Context) error {
+	w.log.Info("worker started")
+	defer w.log.Info("worker finished")
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.scan(ctx); err != nil && ctx.Err() == nil {
+			w.log.Error("failed to re-enrich persons", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) scan(ctx context.Context) error {
+	now := w.now()
+	persons, err := w.deps.DB.GetStalePersonInfo(ctx, schema.StaleRequest{
+		StaleBefore: now.Add(-w.cfg.StaleAfter),
+		RetryBefore: now.Add(-w.cfg.RetryAfter),
+		Count:       w.cfg.BatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, info := range persons {
+		if err := w.refresh(ctx, info); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			w.log.Error("failed to re-enrich person", zap.Int("id", info.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// attributes returns what to look up: everything for stale persons,
+// otherwise the pending and missing attributes, leaving out those no
+// provider fills and those set by the caller. When localizing, the country
+// comes first so that the other lookups use it.
+func (w *Worker) attributes(info schema.PersonInfo) []string {
+	candidates := append(slices.Clone(info.Pending), info.Missing()...)
+	if info.EnrichedAt == nil || info.EnrichedAt.Before(w.now().Add(-w.cfg.StaleAfter)) {
+		candidates = candidates[:0]
+		for _, p := range w.deps.Providers {
+			candidates = append(candidates, p.Attribute())
+		}
+	}
+
+	ret := make([]string, 0, len(candidates))
+	for _, attr := range candidates {
+		if _, ok := w.providers[attr]; !ok || info.SetByCaller(attr) || slices.Contains(ret, attr) {
+			continue
+		}
+		ret = append(ret, attr)
+	}
+
+	if i := slices.Index(ret, schema.AttrCountry); w.cfg.Localize && i > 0 {
+		ret = slices.Insert(slices.Delete(ret, i, i+1), 0, schema.AttrCountry)
+	}
+	return ret
+}
+
+func (w *Worker) refresh(ctx context.Context, info schema.PersonInfo) error {
+	update := schema.EnrichmentUpdate{
+		ID:         info.ID,
+		Version:    info.Version,
+		Values:     map[string]any{},
+		Enrichment: map[string]schema.Enrichment{},
+	}
+	for _, attr := range w.attributes(info) {
+		if err := w.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		res, err := w.lookup(ctx, &info, attr)
+		if err != nil {
+			w.log.Warn("failed to look up attribute",
+				zap.Int("id", info.ID), zap.String("attribute", attr), zap.Error(err))
+			update.Failed = append(update.Failed, attr)
+			continue
+		}
+		update.Values[attr] = res.Value
+		update.Enrichment[attr] = res.Enrichment
+	}
+
+	err := w.deps.DB.UpdateEnrichment(ctx, update)
+	if errors.Is(err, userdb.ErrNotFound) {
+		w.log.Debug("person changed or deleted, skipping", zap.Int("id", info.ID))
+		return nil
+	}
+	return err
+}
+
+// lookup asks the provider of attr, storing the answer in info so that
+// later lookups see it.
+func (w *Worker) lookup(ctx context.Context, info *schema.PersonInfo, attr string) (api.Answer, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	defer cancel()
+
+	q := api.Query{Name: info.Name, MinSamples: w.cfg.MinSamples}
+	if w.cfg.Localize {
+		q.CountryID = info.Country
+	}
+
+	p := w.providers[attr]
+	res, err := p.Lookup(ctx, q)
+	if err != nil {
+		return api.Answer{}, err
+	}
+	if !info.SetAttribute(attr, res.Value) {
+		return api.Answer{}, fmt.Errorf("%s: unexpected %T value for %s", p.Name(), res.Value, attr)
+	}
+	return res, nil
+}
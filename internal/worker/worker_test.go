@@ -0,0 +1,221 @@
+package worker
+
+import (
+	"context"
+	"dataservice/internal/api"
+	"dataservice/internal/api/ageapi"
+	"dataservice/internal/api/genderapi"
+	"dataservice/internal/api/nationalizeapi"
+	"dataservice/internal/schema"
+	"dataservice/internal/userdb"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 27, 0, 0, 0, 0, time.UTC)
+	recent := now.Add(-48 * time.Hour)
+	stale := now.Add(-60 * 24 * time.Hour)
+
+	agify := schema.Enrichment{Provider: "agify", Count: 10, FetchedAt: now}
+	genderize := schema.Enrichment{Provider: "genderize", Count: 20, FetchedAt: now}
+	nationalize := schema.Enrichment{Provider: "nationalize", Count: 30, FetchedAt: now}
+	caller := schema.Enrichment{Provider: schema.ProviderCaller, FetchedAt: recent}
+
+	ctrl := gomock.NewController(t)
+	api := api.NewAPIMock(ctrl)
+	db := userdb.NewMockDB(ctrl)
+
+	w := New(Config{Rate: 1000, BatchSize: 10}, Dependencies{
+		Providers: api.Providers(),
+		DB:        db,
+		Log:       zap.NewNop(),
+	})
+	w.now = func() time.Time { return now }
+
+	db.EXPECT().GetStalePersonInfo(gomock.Any(), schema.StaleRequest{
+		StaleBefore: now.Add(-defaultStaleAfter),
+		RetryBefore: now.Add(-defaultRetryAfter),
+		Count:       10,
+	}).Return([]schema.PersonInfo{
+		{
+			ID: 1, Name: "Dmitry", Age: 22, Gender: "male",
+			Pending:    []string{schema.AttrCountry},
+			EnrichedAt: &recent,
+			Enrichment: map[string]schema.Enrichment{schema.AttrAge: agify},
+			Version:    3,
+		},
+		{
+			ID: 2, Name: "Ivan", Age: 30, Gender: "male", Country: "unknown",
+			EnrichedAt: &recent,
+		},
+		{
+			ID: 3, Name: "Anna", Age: 40, Gender: "female", Country: "RU",
+			EnrichedAt: &stale,
+			Enrichment: map[string]schema.Enrichment{schema.AttrAge: caller},
+			Version:    1,
+		},
+	}, nil)
+
+	api.Nationalize.EXPECT().Get(gomock.Any(), "Dmitry").
+		Return(nationalizeapi.Result{Country: "RU", Enrichment: nationalize}, nil)
+	db.EXPECT().UpdateEnrichment(gomock.Any(), schema.EnrichmentUpdate{
+		ID: 1, Version: 3,
+		Values:     map[string]any{schema.AttrCountry: "RU"},
+		Enrichment: map[string]schema.Enrichment{schema.AttrCountry: nationalize},
+	}).Return(nil)
+
+	api.Nationalize.EXPECT().Get(gomock.Any(), "Ivan").
+		Return(nationalizeapi.Result{}, errors.New("nationalize is down"))
+	db.EXPECT().UpdateEnrichment(gomock.Any(), schema.EnrichmentUpdate{
+		ID:         2,
+		Values:     map[string]any{},
+		Enrichment: map[string]schema.Enrichment{},
+		Failed:     []string{schema.AttrCountry},
+	}).Return(nil)
+
+	// The age set by hand is kept, and a person changed meanwhile is
+	// skipped.
+	api.Gender.EXPECT().Get(gomock.Any(), "Anna").
+		Return(genderapi.Result{Gender: "female", Enrichment: genderize}, nil)
+	api.Nationalize.EXPECT().Get(gomock.Any(), "Anna").
+		Return(nationalizeapi.Result{Country: "UA", Enrichment: nationalize}, nil)
+	db.EXPECT().UpdateEnrichment(gomock.Any(), schema.EnrichmentUpdate{
+		ID: 3, Version: 1,
+		Values: map[string]any{schema.AttrGender: "female", schema.AttrCountry: "UA"},
+		Enrichment: map[string]schema.Enrichment{
+			schema.AttrGender:  genderize,
+			schema.AttrCountry: nationalize,
+		},
+	}).Return(userdb.ErrNotFound)
+
+	require.NoError(t, w.scan(ctx))
+}
+
+func TestScanCallerCountry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	stale := now.Add(-60 * 24 * time.Hour)
+
+	agify := schema.Enrichment{Provider: "agify", Count: 100, FetchedAt: now, CountryID: "RU"}
+	genderize := schema.Enrichment{Provider: "genderize", Count: 200, FetchedAt: now, CountryID: "RU"}
+
+	ctrl := gomock.NewController(t)
+	api := api.NewAPIMock(ctrl)
+	db := userdb.NewMockDB(ctrl)
+
+	w := New(Config{Rate: 1000, BatchSize: 10, Localize: true}, Dependencies{
+		Providers: api.Providers(),
+		DB:        db,
+		Log:       zap.NewNop(),
+	})
+	w.now = func() time.Time { return now }
+
+	db.EXPECT().GetStalePersonInfo(gomock.Any(), gomock.Any()).Return([]schema.PersonInfo{{
+		ID: 4, Name: "Dmitry", Age: 22, Gender: "male", Country: "RU",
+		EnrichedAt: &stale,
+		Enrichment: map[string]schema.Enrichment{
+			schema.AttrCountry: {Provider: schema.ProviderCaller, FetchedAt: stale},
+		},
+	}}, nil)
+
+	// The country given in PUT / is not guessed again and still localizes
+	// the lookups.
+	api.Age.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "RU").
+		Return(ageapi.Result{Age: 23, Enrichment: agify}, nil)
+	api.Gender.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "RU").
+		Return(genderapi.Result{Gender: "male", Enrichment: genderize}, nil)
+	db.EXPECT().UpdateEnrichment(gomock.Any(), schema.EnrichmentUpdate{
+		ID:     4,
+		Values: map[string]any{schema.AttrAge: 23, schema.AttrGender: "male"},
+		Enrichment: map[string]schema.Enrichment{
+			schema.AttrAge:    agify,
+			schema.AttrGender: genderize,
+		},
+	}).Return(nil)
+
+	require.NoError(t, w.scan(ctx))
+}
+
+func TestScanLocalizedCountryFirst(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	stale := now.Add(-60 * 24 * time.Hour)
+
+	agify := schema.Enrichment{Provider: "agify", Count: 100, FetchedAt: now, CountryID: "RU"}
+	genderize := schema.Enrichment{Provider: "genderize", Count: 200, FetchedAt: now, CountryID: "RU"}
+	nationalize := schema.Enrichment{Provider: "nationalize", Count: 300, FetchedAt: now}
+
+	ctrl := gomock.NewController(t)
+	api := api.NewAPIMock(ctrl)
+	db := userdb.NewMockDB(ctrl)
+
+	w := New(Config{Rate: 1000, BatchSize: 10, Localize: true}, Dependencies{
+		Providers: api.Providers(),
+		DB:        db,
+		Log:       zap.NewNop(),
+	})
+	w.now = func() time.Time { return now }
+
+	db.EXPECT().GetStalePersonInfo(gomock.Any(), gomock.Any()).Return([]schema.PersonInfo{{
+		ID: 5, Name: "Dmitry", Age: 22, Gender: "male", Country: "UA",
+		EnrichedAt: &stale,
+	}}, nil)
+
+	// The country is guessed again before it localizes age and gender.
+	gomock.InOrder(
+		api.Nationalize.EXPECT().Get(gomock.Any(), "Dmitry").
+			Return(nationalizeapi.Result{Country: "RU", Enrichment: nationalize}, nil),
+		api.Age.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "RU").
+			Return(ageapi.Result{Age: 23, Enrichment: agify}, nil),
+		api.Gender.EXPECT().GetLocalized(gomock.Any(), "Dmitry", "RU").
+			Return(genderapi.Result{Gender: "male", Enrichment: genderize}, nil),
+	)
+	db.EXPECT().UpdateEnrichment(gomock.Any(), schema.EnrichmentUpdate{
+		ID:     5,
+		Values: map[string]any{schema.AttrAge: 23, schema.AttrGender: "male", schema.AttrCountry: "RU"},
+		Enrichment: map[string]schema.Enrichment{
+			schema.AttrAge:     agify,
+			schema.AttrGender:  genderize,
+			schema.AttrCountry: nationalize,
+		},
+	}).Return(nil)
+
+	require.NoError(t, w.scan(ctx))
+}
+
+func TestRunStops(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	db := userdb.NewMockDB(ctrl)
+
+	w := New(Config{Interval: time.Hour}, Dependencies{
+		Providers: api.NewAPIMock(ctrl).Providers(),
+		DB:        db,
+		Log:       zap.NewNop(),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	db.EXPECT().GetStalePersonInfo(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, schema.StaleRequest) ([]schema.PersonInfo, error) {
+			cancel()
+			return nil, context.Canceled
+		})
+
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop")
+	}
+}